@@ -3,11 +3,17 @@
 package buffer
 
 import (
-	"math"
+	"context"
+	"time"
 
+	"github.com/mohae/firkin/internal/evict"
 	"github.com/mohae/firkin/queue"
 )
 
+// ErrClosed is returned when an item is enqueued to a closed ring buffer. It
+// is the same error as queue.ErrClosed.
+var ErrClosed = queue.ErrClosed
+
 // Ring is a ring buffer implementation wrapping queue.Circular.
 type Ring struct {
 	queue.Circular
@@ -19,24 +25,35 @@ func NewRing(size int) *Ring {
 }
 
 // Enqueue enques an item, If the buffer is full, the oldest item will
-// be evicted.
+// be evicted. If the buffer is closed, ErrClosed is returned. If the buffer
+// has a default TTL, the item expires once it has elapsed.
 func (r *Ring) Enqueue(item interface{}) error {
-	r.Lock()
-	// if the buffer is full, move the head forward
-	if r.isFull() {
-		r.Head = int(math.Mod(float64(r.Head+1), float64(cap(r.Items))))
-	}
-	r.Items[r.Tail] = item
-	r.Tail = int(math.Mod(float64(r.Tail+1), float64(cap(r.Items))))
-	r.Unlock()
-	return nil
+	return evict.Enqueue(&r.Circular, item, r.TTL())
+}
+
+// EnqueueTTL enqueues an item that expires once ttl has elapsed; a ttl <= 0
+// means the item never expires. As with Enqueue, if the buffer is full, the
+// oldest item will be evicted.
+func (r *Ring) EnqueueTTL(item interface{}, ttl time.Duration) error {
+	return evict.Enqueue(&r.Circular, item, ttl)
+}
+
+// EnqueueMany enqueues the received items, in order, under a single lock
+// acquisition. As with Enqueue, the oldest items are evicted to make room;
+// if there are more items than the buffer holds, only the last Cap() items
+// are kept. The number of items enqueued is returned. If the buffer is
+// closed, ErrClosed is returned.
+func (r *Ring) EnqueueMany(items []interface{}) (int, error) {
+	return evict.EnqueueMany(&r.Circular, items, r.TTL())
 }
 
-// isFull is an unexported version that expects the caller to handle locking.
-// This eliminates double locking on enqueue
-func (r *Ring) isFull() bool {
-	if r.Head != int(math.Mod(float64(r.Tail+1), float64(cap(r.Items)))) {
-		return false
+// EnqueueWait enqueues an item. A ring buffer is never full, the oldest item
+// is evicted instead, so this never blocks. If ctx is already done, the item
+// is not enqueued and the context's error is returned. If the buffer is
+// closed, ErrClosed is returned.
+func (r *Ring) EnqueueWait(ctx context.Context, item interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	return true
+	return r.Enqueue(item)
 }
@@ -1,7 +1,10 @@
 package buffer
 
 import (
+	"bytes"
+	"context"
 	"testing"
+	"time"
 )
 
 func TestRingBuffer(t *testing.T) {
@@ -178,3 +181,140 @@ func TestBufferPos(t *testing.T) {
 		}
 	}
 }
+
+func TestRingWait(t *testing.T) {
+	r := NewRing(2)
+	done := make(chan interface{})
+	go func() {
+		v, err := r.DequeueWait(context.Background())
+		if err != nil {
+			t.Errorf("unexpected error: %q", err)
+		}
+		done <- v
+	}()
+	time.Sleep(5 * time.Millisecond)
+	_ = r.Enqueue("a")
+	if v := <-done; v != "a" {
+		t.Errorf("expected a, got %v", v)
+	}
+	// a full ring evicts instead of blocking
+	for _, v := range []string{"b", "c", "d"} {
+		if err := r.EnqueueWait(context.Background(), v); err != nil {
+			t.Errorf("unexpected error: %q", err)
+		}
+	}
+	v, err := r.DequeueWait(context.Background())
+	if err != nil || v != "c" {
+		t.Errorf("expected c, got %v: %v", v, err)
+	}
+}
+
+func TestRingClose(t *testing.T) {
+	r := NewRing(2)
+	_ = r.Enqueue("a")
+	_ = r.Close()
+	if err := r.Enqueue("b"); err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+	if r.IsDrained() {
+		t.Error("expected ring not to be drained")
+	}
+	v, ok := r.Dequeue()
+	if !ok || v != "a" {
+		t.Errorf("expected a, got %v", v)
+	}
+	if !r.IsDrained() {
+		t.Error("expected ring to be drained")
+	}
+}
+
+func TestRingEnqueueMany(t *testing.T) {
+	r := NewRing(3)
+	_ = r.Enqueue(0)
+	n, err := r.EnqueueMany([]interface{}{1, 2, 3, 4})
+	if err != nil || n != 4 {
+		t.Errorf("expected 4 items to be enqueued, got %d: %v", n, err)
+	}
+	got := r.DequeueN(5, nil)
+	expected := []interface{}{2, 3, 4}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("item %d: expected %v, got %v", i, v, got[i])
+		}
+	}
+}
+
+func TestRingIterators(t *testing.T) {
+	r := NewRing(3)
+	for i := 0; i < 5; i++ {
+		_ = r.Enqueue(i)
+	}
+	var got []interface{}
+	for v := range r.All() {
+		got = append(got, v)
+	}
+	for v := range r.Drain() {
+		got = append(got, v)
+	}
+	expected := []interface{}{2, 3, 4, 2, 3, 4}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("item %d: expected %v, got %v", i, v, got[i])
+		}
+	}
+	if !r.IsEmpty() {
+		t.Errorf("expected ring to be empty, len was %d", r.Len())
+	}
+}
+
+func TestRingSnapshotRestore(t *testing.T) {
+	r := NewRing(2)
+	for _, v := range []string{"a", "b", "c"} {
+		_ = r.Enqueue(v)
+	}
+	var buf bytes.Buffer
+	if err := r.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r2 := NewRing(5)
+	if err := r2.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	_ = r2.Enqueue("d")
+	for _, v := range []string{"c", "d"} {
+		val, _ := r2.Dequeue()
+		if val != v {
+			t.Errorf("expected %s, got %v", v, val)
+		}
+	}
+}
+
+func TestRingTTL(t *testing.T) {
+	r := NewRing(2)
+	r.SetTTL(time.Millisecond)
+	_ = r.Enqueue("a")
+	_ = r.EnqueueTTL("b", 0)
+	_ = r.EnqueueTTL("c", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	for _, v := range []string{"b", "c"} {
+		val, ok := r.Dequeue()
+		if !ok || val != v {
+			t.Errorf("expected %s, got %v", v, val)
+		}
+	}
+	_, _ = r.EnqueueMany([]interface{}{"d", "e"})
+	time.Sleep(5 * time.Millisecond)
+	if v, ok := r.Dequeue(); ok {
+		t.Errorf("expected the ring to be empty, got %v", v)
+	}
+	// a was evicted, not expired
+	if r.Expired() != 2 {
+		t.Errorf("expected 2 expired items, got %d", r.Expired())
+	}
+}
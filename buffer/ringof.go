@@ -0,0 +1,33 @@
+package buffer
+
+import "github.com/mohae/firkin/queue"
+
+// RingOf is a ring buffer of T wrapping queue.CircularOf. It has the same
+// semantics as Ring.
+type RingOf[T any] struct {
+	queue.CircularOf[T]
+}
+
+// NewRingOf returns a ring buffer of T initialized with 'size' slots.
+func NewRingOf[T any](size int) *RingOf[T] {
+	return &RingOf[T]{*queue.NewCircularOf[T](size)}
+}
+
+// Enqueue enqueues an item. If the buffer is full, the oldest item will be
+// evicted.
+func (r *RingOf[T]) Enqueue(item T) error {
+	r.Lock()
+	// if the buffer is full, move the head forward
+	if r.isFull() {
+		r.Head = (r.Head + 1) % len(r.Items)
+	}
+	r.Items[r.Tail] = item
+	r.Tail = (r.Tail + 1) % len(r.Items)
+	r.Unlock()
+	return nil
+}
+
+// isFull is an unexported version that expects the caller to handle locking.
+func (r *RingOf[T]) isFull() bool {
+	return r.Head == (r.Tail+1)%len(r.Items)
+}
@@ -0,0 +1,44 @@
+package buffer
+
+import (
+	"testing"
+)
+
+func TestRingOf(t *testing.T) {
+	tests := []struct {
+		size      int
+		items     []int
+		dequeue   int
+		enqueue   []int
+		remaining []int
+	}{
+		{4, []int{0, 1, 2}, 2, []int{3, 4}, []int{2, 3, 4}},
+		{4, []int{0, 1, 2, 3}, 3, []int{4, 5}, []int{3, 4, 5}},
+		{4, []int{0, 1, 2, 3}, 3, []int{4, 5, 6, 7, 8}, []int{5, 6, 7, 8}},
+		{2, []int{0, 1, 2, 3, 4}, 0, []int{}, []int{3, 4}},
+	}
+	for i, test := range tests {
+		r := NewRingOf[int](test.size)
+		if r.Cap() != test.size {
+			t.Errorf("%d: expected cap to be %d, got %d", i, test.size, r.Cap())
+		}
+		for _, v := range test.items {
+			_ = r.Enqueue(v)
+		}
+		for j := 0; j < test.dequeue; j++ {
+			_, _ = r.Dequeue()
+		}
+		for _, v := range test.enqueue {
+			_ = r.Enqueue(v)
+		}
+		if r.Len() != len(test.remaining) {
+			t.Errorf("%d: expected %d items in buffer, got %d", i, len(test.remaining), r.Len())
+		}
+		for j, v := range test.remaining {
+			val, ok := r.Dequeue()
+			if !ok || val != v {
+				t.Errorf("%d remaining #%d: expected %d, got %d (%t)", i, j, v, val, ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package codec provides the encoding of container items, along with the
+// snapshot format used by the containers' Snapshot and Restore methods.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// Codec converts items to and from bytes.
+type Codec interface {
+	Marshal(item interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// Gob is a Codec that uses encoding/gob. The item's concrete type is encoded
+// along with it, so items are decoded as the same type they were encoded
+// as. Types other than gob's basic types must be registered using
+// gob.Register.
+type Gob struct{}
+
+// gobItem wraps an item so that gob encodes its concrete type.
+type gobItem struct {
+	V interface{}
+}
+
+// Marshal gob encodes the item.
+func (Gob) Marshal(item interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobItem{V: item}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a gob encoded item.
+func (Gob) Unmarshal(data []byte) (interface{}, error) {
+	var it gobItem
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&it); err != nil {
+		return nil, err
+	}
+	return it.V, nil
+}
+
+// JSON is a Codec that uses encoding/json. JSON doesn't record the item's
+// type: if New is nil, items are decoded as encoding/json decodes into an
+// interface{}, e.g. numbers become float64. If New is set, it must return a
+// pointer to a new value that each item is decoded into; the value, not the
+// pointer, is returned.
+type JSON struct {
+	New func() interface{}
+}
+
+// Marshal JSON encodes the item.
+func (JSON) Marshal(item interface{}) ([]byte, error) {
+	return json.Marshal(item)
+}
+
+// Unmarshal decodes a JSON encoded item.
+func (j JSON) Unmarshal(data []byte) (interface{}, error) {
+	if j.New == nil {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	p := j.New()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(p).Elem().Interface(), nil
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func init() {
+	gob.Register(point{})
+}
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		item     interface{}
+		expected interface{}
+	}{
+		{"gob int", Gob{}, 42, 42},
+		{"gob string", Gob{}, "a", "a"},
+		{"gob struct", Gob{}, point{1, 2}, point{1, 2}},
+		{"gob nil", Gob{}, nil, nil},
+		{"json int", JSON{}, 42, 42.0},
+		{"json string", JSON{}, "a", "a"},
+		{"json new", JSON{New: func() interface{} { return new(point) }}, point{1, 2}, point{1, 2}},
+	}
+	for _, test := range tests {
+		data, err := test.codec.Marshal(test.item)
+		if err != nil {
+			t.Errorf("%s: marshal: unexpected error: %q", test.name, err)
+			continue
+		}
+		v, err := test.codec.Unmarshal(data)
+		if err != nil {
+			t.Errorf("%s: unmarshal: unexpected error: %q", test.name, err)
+			continue
+		}
+		if v != test.expected {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, v)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		s     Snapshot
+	}{
+		{Gob{}, Snapshot{Kind: "queue", Cap: 8, InitCap: 4, ShiftPercent: 50, Items: []interface{}{0, "a", 2}}},
+		{Gob{}, Snapshot{Kind: "stack", Cap: 2, InitCap: 2, Bounded: true, Items: []interface{}{}}},
+		{JSON{}, Snapshot{Kind: "heap", Cap: 3, Items: []interface{}{"a", "b"}, Priorities: []int{5, -1}}},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, test.codec, &test.s); err != nil {
+			t.Errorf("%d: encode: unexpected error: %q", i, err)
+			continue
+		}
+		s, err := Decode(&buf, test.codec)
+		if err != nil {
+			t.Errorf("%d: decode: unexpected error: %q", i, err)
+			continue
+		}
+		if s.Kind != test.s.Kind || s.Cap != test.s.Cap || s.InitCap != test.s.InitCap || s.ShiftPercent != test.s.ShiftPercent || s.Bounded != test.s.Bounded {
+			t.Errorf("%d: expected %+v, got %+v", i, test.s, *s)
+		}
+		if len(s.Items) != len(test.s.Items) || len(s.Priorities) != len(test.s.Priorities) {
+			t.Errorf("%d: expected %+v, got %+v", i, test.s, *s)
+			continue
+		}
+		for j, v := range test.s.Items {
+			if s.Items[j] != v {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, v, s.Items[j])
+			}
+		}
+		for j, p := range test.s.Priorities {
+			if s.Priorities[j] != p {
+				t.Errorf("%d: priority %d: expected %d, got %d", i, j, p, s.Priorities[j])
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("nope")), Gob{}); err != ErrBadSnapshot {
+		t.Errorf("expected %q, got %v", ErrBadSnapshot, err)
+	}
+	var buf bytes.Buffer
+	_ = Encode(&buf, Gob{}, &Snapshot{Kind: "queue", Items: []interface{}{1, 2}})
+	data := buf.Bytes()
+	if _, err := Decode(bytes.NewReader(data[:len(data)-3]), Gob{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %q, got %v", io.ErrUnexpectedEOF, err)
+	}
+	// capacities out of range are rejected before they are used
+	for i, s := range []*Snapshot{
+		{Kind: "queue", Cap: -1},
+		{Kind: "queue", Cap: MaxCap + 1},
+		{Kind: "queue", InitCap: -5},
+		{Kind: "queue", Cap: 4, InitCap: 1 << 40},
+	} {
+		buf.Reset()
+		_ = Encode(&buf, Gob{}, s)
+		if _, err := Decode(&buf, Gob{}); !errors.Is(err, ErrBadSnapshot) {
+			t.Errorf("%d: expected %q, got %v", i, ErrBadSnapshot, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// magic identifies a snapshot; the last byte is the format version.
+var magic = [4]byte{'f', 'k', 's', 1}
+
+// ErrBadSnapshot is returned when the data being restored is not a snapshot.
+var ErrBadSnapshot = errors.New("codec: not a snapshot")
+
+// MaxCap is the largest capacity, and initial capacity, that Decode accepts.
+// They are read from the data being restored and used to allocate the
+// container, so a corrupt snapshot must not be able to request an arbitrary
+// allocation.
+const MaxCap = 1 << 26
+
+// Snapshot is the state of a container: its configuration and its items.
+type Snapshot struct {
+	Kind         string // the kind of container, e.g. "queue"
+	Cap          int
+	InitCap      int
+	ShiftPercent int
+	Bounded      bool
+	Items        []interface{} // head to tail for queues; bottom to top for stacks
+	Priorities   []int         // for priority queues: the priority of each item
+}
+
+// Encode writes the snapshot to w; the items are encoded using c.
+func Encode(w io.Writer, c Codec, s *Snapshot) error {
+	if s.Priorities != nil && len(s.Priorities) != len(s.Items) {
+		return fmt.Errorf("codec: snapshot has %d items and %d priorities", len(s.Items), len(s.Priorities))
+	}
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	buf = append(buf, magic[:]...)
+	buf = binary.AppendUvarint(buf, uint64(len(s.Kind)))
+	buf = append(buf, s.Kind...)
+	buf = binary.AppendVarint(buf, int64(s.Cap))
+	buf = binary.AppendVarint(buf, int64(s.InitCap))
+	buf = binary.AppendVarint(buf, int64(s.ShiftPercent))
+	var flags byte
+	if s.Bounded {
+		flags |= 1
+	}
+	if s.Priorities != nil {
+		flags |= 2
+	}
+	buf = append(buf, flags)
+	buf = binary.AppendUvarint(buf, uint64(len(s.Items)))
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	for i, item := range s.Items {
+		data, err := c.Marshal(item)
+		if err != nil {
+			return err
+		}
+		buf = binary.AppendUvarint(buf[:0], uint64(len(data)))
+		buf = append(buf, data...)
+		if s.Priorities != nil {
+			buf = binary.AppendVarint(buf, int64(s.Priorities[i]))
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Decode reads a snapshot from r; the items are decoded using c.
+func Decode(r io.Reader, c Codec) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	var m [4]byte
+	if _, err := io.ReadFull(br, m[:]); err != nil || m != magic {
+		return nil, ErrBadSnapshot
+	}
+	s := &Snapshot{}
+	kind, err := readBytes(br)
+	if err != nil {
+		return nil, err
+	}
+	s.Kind = string(kind)
+	for _, p := range []*int{&s.Cap, &s.InitCap, &s.ShiftPercent} {
+		v, err := binary.ReadVarint(br)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if p != &s.ShiftPercent && (v < 0 || v > MaxCap) {
+			return nil, fmt.Errorf("%w: capacity %d is out of range", ErrBadSnapshot, v)
+		}
+		*p = int(v)
+	}
+	flags, err := br.ReadByte()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	s.Bounded = flags&1 != 0
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	s.Items = make([]interface{}, 0, min(n, 1<<16))
+	if flags&2 != 0 {
+		s.Priorities = make([]int, 0, cap(s.Items))
+	}
+	for i := uint64(0); i < n; i++ {
+		data, err := readBytes(br)
+		if err != nil {
+			return nil, err
+		}
+		item, err := c.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		s.Items = append(s.Items, item)
+		if s.Priorities != nil {
+			p, err := binary.ReadVarint(br)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			s.Priorities = append(s.Priorities, int(p))
+		}
+	}
+	return s, nil
+}
+
+// readBytes reads a length prefixed byte slice. The slice grows as the
+// data is read, so a corrupt length can't cause a huge allocation.
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(l)); err != nil {
+		return nil, unexpected(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF: a snapshot ended
+// before all of it was read.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
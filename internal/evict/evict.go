@@ -0,0 +1,17 @@
+// Package evict lets package buffer enqueue onto a queue.Circular, evicting
+// the oldest item when it is full, without that being part of Circular's
+// API: a circular queue returns an error when it is full. The funcs are set
+// by package queue when it is initialized.
+package evict
+
+import "time"
+
+var (
+	// Enqueue adds an item that expires once ttl has elapsed to c, a
+	// *queue.Circular; a ttl <= 0 means the item never expires. If c is
+	// full, its oldest item is evicted to make room.
+	Enqueue func(c interface{}, item interface{}, ttl time.Duration) error
+	// EnqueueMany adds the items, in order, to c, as Enqueue does, under a
+	// single lock acquisition. The number of items enqueued is returned.
+	EnqueueMany func(c interface{}, items []interface{}, ttl time.Duration) (int, error)
+)
@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+)
+
+// minCalendarBuckets is the fewest buckets a Calendar has.
+const minCalendarBuckets = 2
+
+// calendarItem is an item in a Calendar.
+type calendarItem struct {
+	value    interface{}
+	priority int
+	seq      uint64
+}
+
+func (a calendarItem) before(b calendarItem) bool {
+	return a.priority < b.priority || (a.priority == b.priority && a.seq < b.seq)
+}
+
+// Calendar is a thread-safe calendar queue: a priority queue, for integer
+// priorities, e.g. times, that is organized like a desk calendar. Each
+// bucket holds the items in a range of priorities, a day, of the bucket
+// width; together, the buckets make up a year, and items whose priorities
+// are a year, or more, apart share a bucket. The lowest priority is popped
+// first by scanning the buckets from the current one; items with the same
+// priority are popped in the order they were inserted. The number of
+// buckets and their width are adjusted as the queue grows and shrinks, so
+// that both inserting and popping take O(1) on average, regardless of the
+// number of items.
+type Calendar struct {
+	mu      sync.Mutex
+	buckets [][]calendarItem // each bucket is sorted
+	width   int              // the range of priorities of a bucket
+	cur     int              // the virtual bucket, priority / width, being scanned
+	n       int
+	seq     uint64
+}
+
+// NewCalendar returns a new, empty, calendar queue.
+func NewCalendar() *Calendar {
+	return &Calendar{buckets: make([][]calendarItem, minCalendarBuckets), width: 1}
+}
+
+// vbucket returns the virtual bucket of the priority: its "day" since
+// priority 0.
+func (c *Calendar) vbucket(priority int) int {
+	v := priority / c.width
+	if priority < 0 && priority%c.width != 0 {
+		v--
+	}
+	return v
+}
+
+// index returns the index of the bucket for the virtual bucket.
+func (c *Calendar) index(vbucket int) int {
+	i := vbucket % len(c.buckets)
+	if i < 0 {
+		i += len(c.buckets)
+	}
+	return i
+}
+
+// Insert adds a value with the received priority. It never returns an error.
+func (c *Calendar) Insert(value interface{}, priority int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(calendarItem{value: value, priority: priority, seq: c.seq})
+	c.seq++
+	c.n++
+	if c.n > 2*len(c.buckets) {
+		c.resize(2 * len(c.buckets))
+	}
+	return nil
+}
+
+// insert adds the item to its bucket. The caller is expected to handle
+// locking.
+func (c *Calendar) insert(item calendarItem) {
+	vb := c.vbucket(item.priority)
+	// the scan starts at the earliest bucket that may hold the first item
+	if c.n == 0 || vb < c.cur {
+		c.cur = vb
+	}
+	i := c.index(vb)
+	b := c.buckets[i]
+	j, _ := slices.BinarySearchFunc(b, item, func(a, b calendarItem) int {
+		if a.before(b) {
+			return -1
+		}
+		return 1
+	})
+	c.buckets[i] = slices.Insert(b, j, item)
+}
+
+// next returns the index of the bucket whose first item is the first item
+// in the queue, which must not be empty.
+func (c *Calendar) next() int {
+	// scan a year of buckets for an item that is in the current day
+	for range c.buckets {
+		i := c.index(c.cur)
+		if b := c.buckets[i]; len(b) > 0 && c.vbucket(b[0].priority) == c.cur {
+			return i
+		}
+		c.cur++
+	}
+	// the items are sparse: search for the first one directly
+	m := -1
+	for i, b := range c.buckets {
+		if len(b) > 0 && (m < 0 || b[0].before(c.buckets[m][0])) {
+			m = i
+		}
+	}
+	c.cur = c.vbucket(c.buckets[m][0].priority)
+	return m
+}
+
+// PopValue removes the lowest priority item and returns its value and
+// priority. If the queue is empty, a false will be returned.
+func (c *Calendar) PopValue() (interface{}, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n == 0 {
+		return nil, 0, false
+	}
+	i := c.next()
+	item := c.buckets[i][0]
+	c.buckets[i] = slices.Delete(c.buckets[i], 0, 1)
+	c.n--
+	if c.n < len(c.buckets)/2 && len(c.buckets) > minCalendarBuckets {
+		c.resize(len(c.buckets) / 2)
+	}
+	return item.value, item.priority, true
+}
+
+// PeekValue returns the value and priority of the lowest priority item
+// without removing it. If the queue is empty, a false will be returned.
+func (c *Calendar) PeekValue() (interface{}, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n == 0 {
+		return nil, 0, false
+	}
+	item := c.buckets[c.next()][0]
+	return item.value, item.priority, true
+}
+
+// Len returns the number of items in the queue.
+func (c *Calendar) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// Reset removes all of the items from the queue.
+func (c *Calendar) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buckets = make([][]calendarItem, minCalendarBuckets)
+	c.width = 1
+	c.cur = 0
+	c.n = 0
+}
+
+// resize redistributes the items over n buckets, with a width estimated
+// from the items at the front of the queue.
+func (c *Calendar) resize(n int) {
+	items := make([]calendarItem, 0, c.n)
+	for _, b := range c.buckets {
+		items = append(items, b...)
+	}
+	c.width = calendarWidth(items)
+	c.buckets = make([][]calendarItem, max(n, minCalendarBuckets))
+	m := c.n
+	c.n = 0
+	for _, item := range items {
+		c.insert(item)
+		c.n++
+	}
+	c.n = m
+}
+
+// calendarSample is the number of items used to estimate the bucket width.
+const calendarSample = 25
+
+// calendarWidth estimates a bucket width for the items: three times the
+// average separation of the first items in the queue, ignoring separations
+// more than twice the average, so that a bucket holds about three items.
+func calendarWidth(items []calendarItem) int {
+	// the lowest priorities, in order
+	var first []int
+	for _, item := range items {
+		if len(first) == calendarSample && item.priority >= first[calendarSample-1] {
+			continue
+		}
+		i, _ := slices.BinarySearch(first, item.priority)
+		first = slices.Insert(first, i, item.priority)
+		if len(first) > calendarSample {
+			first = first[:calendarSample]
+		}
+	}
+	if len(first) < 2 {
+		return 1
+	}
+	avg := (first[len(first)-1] - first[0]) / (len(first) - 1)
+	var sum, n int
+	for i := 1; i < len(first); i++ {
+		if d := first[i] - first[i-1]; d <= 2*avg {
+			sum += d
+			n++
+		}
+	}
+	if n == 0 || sum == 0 {
+		return max(3*avg, 1)
+	}
+	return max(3*sum/n, 1)
+}
@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestCalendar(t *testing.T) {
+	c := NewCalendar()
+	tests := []struct {
+		value    string
+		priority int
+	}{
+		{"a", 50}, {"b", -7}, {"c", 1000000}, {"d", 50}, {"e", 3}, {"f", -7}, {"g", 51},
+	}
+	for _, test := range tests {
+		_ = c.Insert(test.value, test.priority)
+	}
+	if v, p, ok := c.PeekValue(); !ok || v != "b" || p != -7 {
+		t.Errorf("expected to peek b -7, got %v %d (%t)", v, p, ok)
+	}
+	// an item inserted before the item being peeked at comes first
+	_ = c.Insert("h", -8)
+	for i, expected := range []string{"h", "b", "f", "e", "a", "d", "g", "c"} {
+		v, _, ok := c.PopValue()
+		if !ok || v != expected {
+			t.Errorf("%d: expected %s, got %v (%t)", i, expected, v, ok)
+		}
+	}
+	if _, _, ok := c.PopValue(); ok || c.Len() != 0 {
+		t.Errorf("expected the queue to be empty, len was %d", c.Len())
+	}
+}
+
+func TestCalendarResize(t *testing.T) {
+	c := NewCalendar()
+	r := rand.New(rand.NewSource(7))
+	var expected []int
+	// grow the calendar, with a mix of dense and sparse priorities
+	for i := 0; i < 3000; i++ {
+		p := r.Intn(100)
+		if i%10 == 0 {
+			p = r.Intn(1 << 40)
+		}
+		_ = c.Insert(p, p)
+		expected = append(expected, p)
+	}
+	if len(c.buckets) < 1024 {
+		t.Errorf("expected the calendar to have grown, it has %d buckets", len(c.buckets))
+	}
+	slices.Sort(expected)
+	for i, p := range expected {
+		_, got, ok := c.PopValue()
+		if !ok || got != p {
+			t.Fatalf("%d: expected %d, got %d (%t)", i, p, got, ok)
+		}
+	}
+	if len(c.buckets) != minCalendarBuckets {
+		t.Errorf("expected the calendar to have shrunk to %d buckets, it has %d", minCalendarBuckets, len(c.buckets))
+	}
+	c.Reset()
+	if c.Len() != 0 {
+		t.Errorf("expected len 0 after reset, got %d", c.Len())
+	}
+}
@@ -1,8 +1,15 @@
 package queue
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"iter"
 	"math"
+	"time"
+
+	"github.com/mohae/firkin/codec"
+	"github.com/mohae/firkin/internal/evict"
 )
 
 // Circular is a bounded queue implemented as a circular queue.  Even though
@@ -29,33 +36,227 @@ func NewCircular(size int) *Circular {
 	return &c
 }
 
-// Enqueue will return an error if the queue is full
+// Enqueue will return an error if the queue is full or ErrClosed if the
+// queue is closed. If the queue has a default TTL, the item expires once it
+// has elapsed.
 func (c *Circular) Enqueue(item interface{}) error {
 	c.Lock()
+	defer c.Unlock()
+	return c.enqueue(item, c.ttl)
+}
+
+// EnqueueTTL adds an item to the queue that expires once ttl has elapsed.
+// Expired items are discarded, instead of returned, by Dequeue and Peek. A
+// ttl <= 0 means the item never expires. Like Enqueue, an error is returned
+// if the queue is full or closed.
+func (c *Circular) EnqueueTTL(item interface{}, ttl time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.enqueue(item, ttl)
+}
+
+// enqueue is an unexported version that expects the caller to handle
+// locking.
+func (c *Circular) enqueue(item interface{}, ttl time.Duration) error {
+	if c.IsClosed() {
+		return ErrClosed
+	}
 	if c.isFull() {
-		c.Unlock()
 		return fmt.Errorf("queue full: cannot enqueue %v", item)
 	}
+	c.put(item, ttl)
+	return nil
+}
+
+// put stores an item at the tail of the queue, which must not be full. The
+// caller is expected to handle locking.
+func (c *Circular) put(item interface{}, ttl time.Duration) {
 	c.Items[c.Tail] = item
+	c.setDeadline(c.Tail, ttl)
 	c.Tail = int(math.Mod(float64(c.Tail+1), float64(cap(c.Items))))
-	c.Unlock()
+	c.notifyEnqueued()
+}
+
+// EnqueueWait adds an item to the queue. If the queue is full, it blocks
+// until a slot is freed or ctx is done, in which case the item is not
+// enqueued and the context's error is returned. If the queue is, or
+// becomes, closed ErrClosed is returned.
+func (c *Circular) EnqueueWait(ctx context.Context, item interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+	err := c.wait(ctx, c.notFullCond(), func() bool { return !c.isFull() || c.IsClosed() })
+	if err != nil {
+		return err
+	}
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	c.put(item, c.ttl)
+	return nil
+}
+
+func init() {
+	evict.Enqueue = func(c interface{}, item interface{}, ttl time.Duration) error {
+		return c.(*Circular).enqueueEvict(item, ttl)
+	}
+	evict.EnqueueMany = func(c interface{}, items []interface{}, ttl time.Duration) (int, error) {
+		return c.(*Circular).enqueueManyEvict(items, ttl)
+	}
+}
+
+// enqueueEvict adds an item to the queue that expires once ttl has elapsed; a
+// ttl <= 0 means the item never expires. If the queue is full, the oldest
+// item is evicted to make room instead of an error being returned. It is
+// used by buffer.Ring, through package evict. If the queue is closed,
+// ErrClosed is returned.
+func (c *Circular) enqueueEvict(item interface{}, ttl time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	c.evict(item, ttl)
 	return nil
 }
 
+// enqueueManyEvict adds the received items, in order, under a single lock
+// acquisition, each expiring once ttl has elapsed. As with enqueueEvict, the
+// oldest items are evicted to make room; if there are more items than the
+// queue holds, only the last Cap() items are kept. The number of items
+// enqueued is returned. If the queue is closed, ErrClosed is returned.
+func (c *Circular) enqueueManyEvict(items []interface{}, ttl time.Duration) (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.IsClosed() {
+		return 0, ErrClosed
+	}
+	for _, item := range items {
+		c.evict(item, ttl)
+	}
+	return len(items), nil
+}
+
+// evict adds an item to the queue, evicting the oldest item if the queue is
+// full. The caller is expected to handle locking.
+func (c *Circular) evict(item interface{}, ttl time.Duration) {
+	if c.isFull() {
+		c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
+	}
+	c.put(item, ttl)
+}
+
 // Dequeue will remove an item from the queue and return it. If the queue is
-// empty, a false will be returned.
+// empty, a false will be returned. Expired items are discarded.
 func (c *Circular) Dequeue() (interface{}, bool) {
 	c.Lock()
 	item, ok := c.peek()
 	if ok {
 		c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
+		c.notifyDequeued()
 	}
 	c.Unlock()
 	return item, ok
 }
 
+// DequeueWait removes an item from the queue and returns it. If the queue
+// is empty, it blocks until an item is enqueued or ctx is done, in which
+// case the context's error is returned. Once the queue is closed and empty,
+// ErrClosed is returned. Expired items are discarded.
+func (c *Circular) DequeueWait(ctx context.Context) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+	for {
+		err := c.wait(ctx, c.notEmptyCond(), func() bool { return !c.isEmpty() || c.IsClosed() })
+		if err != nil {
+			return nil, err
+		}
+		item, ok := c.peek()
+		if ok {
+			c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
+			c.notifyDequeued()
+			return item, nil
+		}
+		// either all of the items had expired or the queue is closed
+		if c.IsClosed() {
+			return nil, ErrClosed
+		}
+	}
+}
+
+// EnqueueMany adds as many of the received items, in order, to the queue as
+// will fit, under a single lock acquisition. The number of items enqueued is
+// returned. If not all of the items fit, an error is also returned; if the
+// queue is closed, no items are enqueued and ErrClosed is returned. If the
+// queue has a default TTL, the items expire once it has elapsed.
+func (c *Circular) EnqueueMany(items []interface{}) (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.IsClosed() {
+		return 0, ErrClosed
+	}
+	n := cap(c.Items) - 1 - c.plen()
+	if n > len(items) {
+		n = len(items)
+	}
+	// the free slots may wrap around the end of the slice
+	k := copy(c.Items[c.Tail:], items[:n])
+	copy(c.Items, items[k:n])
+	if c.ttl > 0 || c.deadlines != nil {
+		for i := 0; i < n; i++ {
+			c.setDeadline((c.Tail+i)%cap(c.Items), c.ttl)
+		}
+	}
+	c.Tail = (c.Tail + n) % cap(c.Items)
+	if n > 0 && c.notEmpty != nil {
+		c.notEmpty.Broadcast()
+	}
+	if n < len(items) {
+		return n, fmt.Errorf("queue full: enqueued %d of %d items", n, len(items))
+	}
+	return n, nil
+}
+
+// DequeueN removes up to n items from the queue under a single lock
+// acquisition. The items are appended, in order, to dst and the resulting
+// slice is returned. Expired items are discarded and don't count towards n.
+func (c *Circular) DequeueN(n int, dst []interface{}) []interface{} {
+	c.Lock()
+	defer c.Unlock()
+	if c.deadlines != nil {
+		for ; n > 0; n-- {
+			item, ok := c.peek()
+			if !ok {
+				break
+			}
+			c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
+			c.notifyDequeued()
+			dst = append(dst, item)
+		}
+		return dst
+	}
+	if l := c.plen(); n > l {
+		n = l
+	}
+	if n <= 0 {
+		return dst
+	}
+	// the items may wrap around the end of the slice
+	k := cap(c.Items) - c.Head
+	if k > n {
+		k = n
+	}
+	dst = append(dst, c.Items[c.Head:c.Head+k]...)
+	dst = append(dst, c.Items[:n-k]...)
+	c.Head = (c.Head + n) % cap(c.Items)
+	if c.notFull != nil {
+		c.notFull.Broadcast()
+	}
+	return dst
+}
+
 // Peek will return the next item in the queue without removing it from the
-// queue. If the queue is empty, a false will be returned.
+// queue. If the queue is empty, a false will be returned. Expired items at
+// the head of the queue are discarded.
 func (c *Circular) Peek() (interface{}, bool) {
 	c.Lock()
 	defer c.Unlock()
@@ -64,10 +265,16 @@ func (c *Circular) Peek() (interface{}, bool) {
 
 // peek is an unexported version that expects the caller to handle locking.
 func (c *Circular) peek() (interface{}, bool) {
-	if c.isEmpty() {
-		return nil, false
+	for !c.isEmpty() {
+		item, ok := c.live(c.Head)
+		if ok {
+			return item, true
+		}
+		c.Items[c.Head] = nil
+		c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
+		c.notifyDequeued()
 	}
-	return c.Items[c.Head], true
+	return nil, false
 }
 
 // IsEmpty returns whether or not the queue is empty
@@ -77,6 +284,14 @@ func (c *Circular) IsEmpty() bool {
 	return c.isEmpty()
 }
 
+// IsDrained returns whether or not the queue is both closed and empty: no
+// more items will be dequeued from it.
+func (c *Circular) IsDrained() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.IsClosed() && c.isEmpty()
+}
+
 // isEmpty is an unexported version that expects the caller to handle locking.
 // This eliminates double locking on dequeue and peek
 func (c *Circular) isEmpty() bool {
@@ -102,7 +317,8 @@ func (c *Circular) isFull() bool {
 	return false
 }
 
-// Len returns the current length of the queue (# of items in queue)
+// Len returns the current length of the queue (# of items in queue).
+// Expired items that have not been discarded yet are included.
 func (c *Circular) Len() int {
 	c.Lock()
 	defer c.Unlock()
@@ -137,6 +353,13 @@ func (c *Circular) Resize(size int) int {
 	}
 	// tmp slice of remaining Items
 	tmp := make([]interface{}, 0, c.plen())
+	if c.deadlines != nil {
+		dl := make([]time.Time, 0, cap(tmp))
+		for i := 0; i < cap(tmp); i++ {
+			dl = append(dl, c.deadlines[(c.Head+i)%cap(c.Items)])
+		}
+		c.deadlines = dl
+	}
 	for i := 0; i < cap(tmp); i++ {
 		tmp = append(tmp, c.Items[c.Head:c.Head])
 		c.Head = int(math.Mod(float64(c.Head+1), float64(cap(c.Items))))
@@ -148,6 +371,7 @@ func (c *Circular) Resize(size int) int {
 	x := c.Queue.Resize(size + 1)
 	c.Lock()
 	_ = c.zeroQueue()
+	c.notFullCond().Broadcast()
 	c.Unlock()
 	return x
 }
@@ -158,6 +382,7 @@ func (c *Circular) Reset() {
 	c.Lock()
 	c.Tail = 0
 	_ = c.zeroQueue()
+	c.notFullCond().Broadcast()
 	c.Unlock()
 
 }
@@ -174,5 +399,130 @@ func (c *Circular) zeroQueue() int {
 		c.Items = append(c.Items, nil)
 		x++
 	}
+	for c.deadlines != nil && len(c.deadlines) < len(c.Items) {
+		c.deadlines = append(c.deadlines, time.Time{})
+	}
 	return x
 }
+
+// All returns an iterator over the items in the queue, from head to tail.
+// The iterator ranges over a snapshot of the queue taken when iteration
+// starts; the queue is not locked while the items are yielded.
+func (c *Circular) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for _, item := range c.snapshot() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Indexed returns an iterator over the position and item of each item in
+// the queue, from head, position 0, to tail. Like All, it ranges over a
+// snapshot of the queue.
+func (c *Circular) Indexed() iter.Seq2[int, interface{}] {
+	return func(yield func(int, interface{}) bool) {
+		for i, item := range c.snapshot() {
+			if !yield(i, item) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues the items in the queue, yielding
+// each one, until the queue is empty. Items enqueued while draining are also
+// yielded. Stopping the iteration early leaves the remaining items in the
+// queue.
+func (c *Circular) Drain() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for {
+			item, ok := c.Dequeue()
+			if !ok || !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the items in the queue, from head to tail.
+// Expired items are left out.
+func (c *Circular) snapshot() []interface{} {
+	c.Lock()
+	defer c.Unlock()
+	return c.liveItems()
+}
+
+// liveItems is an unexported version of snapshot that expects the caller to
+// handle locking.
+func (c *Circular) liveItems() []interface{} {
+	var tmp []interface{}
+	if c.deadlines != nil {
+		now := c.now()
+		for i := c.Head; i != c.Tail; i = (i + 1) % cap(c.Items) {
+			if c.unexpired(i, now) {
+				tmp = append(tmp, c.Items[i])
+			}
+		}
+		return tmp
+	}
+	if c.Head <= c.Tail {
+		tmp = append(tmp, c.Items[c.Head:c.Tail]...)
+	} else {
+		tmp = make([]interface{}, 0, c.plen())
+		tmp = append(tmp, c.Items[c.Head:]...)
+		tmp = append(tmp, c.Items[:c.Tail]...)
+	}
+	return tmp
+}
+
+// Snapshot writes the queue's items, from head to tail, along with its
+// capacity and initial capacity, to w. The items and the configuration are
+// copied under a single hold of the lock, so that they match, and encoded
+// after it is released. Expired items are left out and the expiry of the
+// others is not kept.
+func (c *Circular) Snapshot(w io.Writer) error {
+	c.Lock()
+	s := &codec.Snapshot{
+		Kind:         "circular",
+		Cap:          cap(c.Items),
+		InitCap:      c.InitCap,
+		ShiftPercent: c.shiftPercent,
+		Bounded:      true,
+		Items:        c.liveItems(),
+	}
+	cd := c.itemCodec()
+	c.Unlock()
+	return codec.Encode(w, cd, s)
+}
+
+// Restore replaces the queue's items and configuration with those of a
+// snapshot written by Snapshot. Any items in the queue will be lost.
+func (c *Circular) Restore(r io.Reader) error {
+	c.Lock()
+	cd := c.itemCodec()
+	c.Unlock()
+	s, err := codec.Decode(r, cd)
+	if err != nil {
+		return err
+	}
+	if s.Kind != "circular" {
+		return fmt.Errorf("cannot restore a %s snapshot to a circular queue", s.Kind)
+	}
+	if len(s.Items) >= s.Cap {
+		return fmt.Errorf("cannot restore %d items to a circular queue with a capacity of %d", len(s.Items), s.Cap-1)
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.InitCap = s.InitCap
+	c.shiftPercent = s.ShiftPercent
+	c.Items = make([]interface{}, s.Cap)
+	copy(c.Items, s.Items)
+	c.deadlines = nil
+	c.Head = 0
+	c.Tail = len(s.Items)
+	c.notEmptyCond().Broadcast()
+	c.notFullCond().Broadcast()
+	return nil
+}
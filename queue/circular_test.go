@@ -1,7 +1,13 @@
 package queue
 
 import (
+	"bytes"
+	"context"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/mohae/firkin/codec"
 )
 
 func TestCircular(t *testing.T) {
@@ -199,3 +205,273 @@ func TestCircularResetResize(t *testing.T) {
 
 	}
 }
+
+func TestCircularWait(t *testing.T) {
+	c := NewCircular(2)
+	for i := 0; i < 2; i++ {
+		if err := c.EnqueueWait(context.Background(), i); err != nil {
+			t.Errorf("enqueue %d: unexpected error: %q", i, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.EnqueueWait(ctx, 2); err != context.DeadlineExceeded {
+		t.Errorf("expected %q, got %v", context.DeadlineExceeded, err)
+	}
+
+	// producers block on the full queue until the consumer makes room
+	var wg sync.WaitGroup
+	for i := 2; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.EnqueueWait(context.Background(), i); err != nil {
+				t.Errorf("enqueue %d: unexpected error: %q", i, err)
+			}
+		}(i)
+	}
+	seen := make(map[interface{}]bool)
+	for i := 0; i < 10; i++ {
+		v, err := c.DequeueWait(context.Background())
+		if err != nil {
+			t.Errorf("dequeue %d: unexpected error: %q", i, err)
+		}
+		seen[v] = true
+	}
+	wg.Wait()
+	if len(seen) != 10 {
+		t.Errorf("expected 10 distinct items, got %d", len(seen))
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := c.DequeueWait(ctx); err != context.Canceled {
+		t.Errorf("expected %q, got %v", context.Canceled, err)
+	}
+}
+
+func TestCircularClose(t *testing.T) {
+	c := NewCircular(2)
+	_ = c.Enqueue(0)
+	_ = c.Enqueue(1)
+	done := make(chan error)
+	go func() {
+		done <- c.EnqueueWait(context.Background(), 2)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error: %q", err)
+	}
+	if err := <-done; err != ErrClosed {
+		t.Errorf("expected blocked enqueue to return %q, got %v", ErrClosed, err)
+	}
+	if err := c.Enqueue(3); err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+	if !c.IsClosed() || c.IsDrained() {
+		t.Errorf("expected queue to be closed but not drained: %t %t", c.IsClosed(), c.IsDrained())
+	}
+	for i := 0; i < 2; i++ {
+		v, err := c.DequeueWait(context.Background())
+		if err != nil || v != i {
+			t.Errorf("expected %d, got %v: %v", i, v, err)
+		}
+	}
+	if _, err := c.DequeueWait(context.Background()); err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+	if !c.IsDrained() {
+		t.Error("expected queue to be drained")
+	}
+}
+
+func TestCircularEnqueueManyDequeueN(t *testing.T) {
+	tests := []struct {
+		size     int
+		pre      int // items enqueued and dequeued first, to wrap
+		items    []interface{}
+		enqueued int
+		err      string
+		n        int
+		expected []interface{}
+	}{
+		{4, 0, []interface{}{0, 1, 2}, 3, "", 4, []interface{}{0, 1, 2}},
+		{4, 0, []interface{}{0, 1, 2, 3, 4, 5}, 4, "queue full: enqueued 4 of 6 items", 4, []interface{}{0, 1, 2, 3}},
+		{4, 3, []interface{}{0, 1, 2, 3}, 4, "", 3, []interface{}{0, 1, 2}},
+		{4, 4, []interface{}{0, 1, 2, 3, 4}, 4, "queue full: enqueued 4 of 5 items", 4, []interface{}{0, 1, 2, 3}},
+		{4, 2, []interface{}{}, 0, "", 1, []interface{}{}},
+	}
+	for i, test := range tests {
+		c := NewCircular(test.size)
+		for j := 0; j < test.pre; j++ {
+			_ = c.Enqueue(j)
+			_, _ = c.Dequeue()
+		}
+		n, err := c.EnqueueMany(test.items)
+		if n != test.enqueued {
+			t.Errorf("%d: expected %d items to be enqueued, got %d", i, test.enqueued, n)
+		}
+		if err != nil && err.Error() != test.err {
+			t.Errorf("%d: expected error to be %q, got %q", i, test.err, err)
+		}
+		if err == nil && test.err != "" {
+			t.Errorf("%d: expected error %q, got none", i, test.err)
+		}
+		got := c.DequeueN(test.n, nil)
+		if len(got) != len(test.expected) {
+			t.Errorf("%d: expected %d items to be dequeued, got %d", i, len(test.expected), len(got))
+			continue
+		}
+		for j, v := range test.expected {
+			if got[j] != v {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, v, got[j])
+			}
+		}
+		if c.Len() != test.enqueued-len(test.expected) {
+			t.Errorf("%d: expected len to be %d, got %d", i, test.enqueued-len(test.expected), c.Len())
+		}
+	}
+}
+
+func TestCircularIterators(t *testing.T) {
+	c := NewCircular(4)
+	// wrap the items around the end of the slice
+	for i := 0; i < 3; i++ {
+		_ = c.Enqueue(i)
+		_, _ = c.Dequeue()
+	}
+	for i := 0; i < 4; i++ {
+		_ = c.Enqueue(i)
+	}
+	for i, v := range c.Indexed() {
+		if v != i {
+			t.Errorf("Indexed: item %d: expected %d, got %v", i, i, v)
+		}
+	}
+	n := 0
+	for v := range c.All() {
+		if v != n {
+			t.Errorf("All: item %d: expected %d, got %v", n, n, v)
+		}
+		n++
+	}
+	if n != 4 {
+		t.Errorf("All: expected 4 items, got %d", n)
+	}
+	n = 0
+	for v := range c.Drain() {
+		if v != n {
+			t.Errorf("Drain: item %d: expected %d, got %v", n, n, v)
+		}
+		n++
+	}
+	if n != 4 || !c.IsEmpty() {
+		t.Errorf("Drain: expected 4 items and an empty queue, got %d items and len %d", n, c.Len())
+	}
+}
+
+func TestCircularSnapshotRestore(t *testing.T) {
+	c := NewCircular(4)
+	c.SetCodec(codec.JSON{})
+	// wrap the items around the end of the slice
+	for i := 0; i < 3; i++ {
+		_ = c.Enqueue("x")
+		_, _ = c.Dequeue()
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		_ = c.Enqueue(v)
+	}
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r := NewCircular(1)
+	r.SetCodec(codec.JSON{})
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	if r.Cap() != 4 || r.InitCap != 5 || r.Len() != 3 {
+		t.Errorf("expected cap 4, InitCap 5, len 3; got %d, %d, %d", r.Cap(), r.InitCap, r.Len())
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		val, _ := r.Dequeue()
+		if val != v {
+			t.Errorf("expected %s, got %v", v, val)
+		}
+	}
+}
+
+func TestCircularTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCircular(3)
+	c.SetClock(clock)
+	_ = c.EnqueueTTL("a", time.Second)
+	_ = c.EnqueueTTL("b", 2*time.Second)
+	_ = c.Enqueue("c")
+	if err := c.EnqueueTTL("d", time.Second); err == nil {
+		t.Error("expected a full queue error, got nil")
+	}
+	clock.Advance(time.Second)
+	var got []interface{}
+	for v := range c.All() {
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("expected [b c], got %v", got)
+	}
+	v, ok := c.Peek()
+	if !ok || v != "b" {
+		t.Errorf("expected b, got %v (%t)", v, ok)
+	}
+	// the expired item was discarded, freeing its slot
+	if c.Len() != 2 {
+		t.Errorf("expected len 2, got %d", c.Len())
+	}
+	c.SetTTL(time.Second)
+	_, _ = c.EnqueueMany([]interface{}{"d"})
+	clock.Advance(time.Second)
+	v, err := c.DequeueWait(context.Background())
+	if err != nil || v != "c" {
+		t.Errorf("expected c, got %v: %v", v, err)
+	}
+	if c.Expired() != 2 {
+		t.Errorf("expected 2 expired items, got %d", c.Expired())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.DequeueWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %q, got %v", context.DeadlineExceeded, err)
+	}
+	if c.Expired() != 3 {
+		t.Errorf("expected 3 expired items, got %d", c.Expired())
+	}
+}
+
+func TestCircularEnqueueEvict(t *testing.T) {
+	c := NewCircular(2)
+	done := make(chan interface{})
+	go func() {
+		v, _ := c.DequeueWait(context.Background())
+		done <- v
+	}()
+	_ = c.enqueueEvict("a", 0)
+	if v := <-done; v != "a" {
+		t.Errorf("expected a, got %v", v)
+	}
+	_ = c.enqueueEvict("b", 0)
+	_ = c.enqueueEvict("c", 0)
+	_ = c.enqueueEvict("d", 0)
+	if n, err := c.enqueueManyEvict([]interface{}{"e"}, 0); n != 1 || err != nil {
+		t.Errorf("expected 1 item enqueued, got %d: %v", n, err)
+	}
+	got := c.DequeueN(3, nil)
+	if len(got) != 2 || got[0] != "d" || got[1] != "e" {
+		t.Errorf("expected [d e], got %v", got)
+	}
+	_ = c.Close()
+	if err := c.enqueueEvict("f", 0); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
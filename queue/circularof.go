@@ -0,0 +1,145 @@
+package queue
+
+import "fmt"
+
+// CircularOf is a bounded queue of T implemented as a circular queue. It has
+// the same semantics as Circular. Even though Items, Head, and Tail are
+// exported, in most cases, they should not be used directly. Use the exported
+// methods to interact with the queue.
+type CircularOf[T any] struct {
+	QueueOf[T]
+	Tail int
+}
+
+// NewCircularOf returns an initialized circular queue of T. The slice is 1
+// slot larger than the requested size for empty/full detection.
+func NewCircularOf[T any](size int) *CircularOf[T] {
+	size++
+	return &CircularOf[T]{QueueOf: QueueOf[T]{InitCap: size, Items: make([]T, size), shiftPercent: shiftPercent}}
+}
+
+// Enqueue will return an error if the queue is full
+func (c *CircularOf[T]) Enqueue(item T) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.isFull() {
+		return fmt.Errorf("queue full: cannot enqueue %v", item)
+	}
+	c.Items[c.Tail] = item
+	c.Tail = (c.Tail + 1) % len(c.Items)
+	return nil
+}
+
+// Dequeue will remove an item from the queue and return it. If the queue is
+// empty, a false will be returned.
+func (c *CircularOf[T]) Dequeue() (T, bool) {
+	c.Lock()
+	defer c.Unlock()
+	item, ok := c.peek()
+	if ok {
+		var zero T
+		c.Items[c.Head] = zero
+		c.Head = (c.Head + 1) % len(c.Items)
+	}
+	return item, ok
+}
+
+// Peek will return the next item in the queue without removing it from the
+// queue. If the queue is empty, a false will be returned.
+func (c *CircularOf[T]) Peek() (T, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.peek()
+}
+
+// peek is an unexported version that expects the caller to handle locking.
+func (c *CircularOf[T]) peek() (T, bool) {
+	if c.isEmpty() {
+		var zero T
+		return zero, false
+	}
+	return c.Items[c.Head], true
+}
+
+// IsEmpty returns whether or not the queue is empty
+func (c *CircularOf[T]) IsEmpty() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.isEmpty()
+}
+
+// isEmpty is an unexported version that expects the caller to handle locking.
+func (c *CircularOf[T]) isEmpty() bool {
+	return c.Head == c.Tail
+}
+
+// IsFull returns whether or not the queue is full
+func (c *CircularOf[T]) IsFull() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.isFull()
+}
+
+// isFull is an unexported version that expects the caller to handle locking.
+func (c *CircularOf[T]) isFull() bool {
+	return c.Head == (c.Tail+1)%len(c.Items)
+}
+
+// Len returns the current length of the queue (# of items in queue)
+func (c *CircularOf[T]) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.plen()
+}
+
+// plen returns the current length of the queue (# items in queue). The
+// caller is expected to handle locking.
+func (c *CircularOf[T]) plen() int {
+	l := c.Tail
+	if c.Tail < c.Head {
+		l += len(c.Items)
+	}
+	return l - c.Head
+}
+
+// Cap returns the current queue capacity:
+//
+//	queue cap = cap(queue) - 1
+func (c *CircularOf[T]) Cap() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.Items) - 1
+}
+
+// Resize resizes the queue to hold size items. A size of 0 resizes the queue
+// to its initial capacity. The queue will not shrink below the number of
+// items in it. Any items in the queue are copied, in order, to the front of
+// the resized queue. The new capacity is returned.
+func (c *CircularOf[T]) Resize(size int) int {
+	c.Lock()
+	defer c.Unlock()
+	if size <= 0 {
+		size = c.InitCap - 1
+	}
+	n := c.plen()
+	if size < n {
+		size = n
+	}
+	tmp := make([]T, size+1)
+	for i := 0; i < n; i++ {
+		tmp[i] = c.Items[(c.Head+i)%len(c.Items)]
+	}
+	c.Items = tmp
+	c.Head = 0
+	c.Tail = n
+	return size
+}
+
+// Reset resets a queue, zeroing out the slots.
+func (c *CircularOf[T]) Reset() {
+	c.Lock()
+	clear(c.Items)
+	c.Head = 0
+	c.Tail = 0
+	c.Unlock()
+}
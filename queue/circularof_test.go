@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestCircularOf(t *testing.T) {
+	tests := []struct {
+		size       int
+		items      []int
+		dequeue    []int
+		enqueue    []int
+		err        string
+		isFull     bool
+		remaining  []int
+		resize     int
+		resizeCap  int
+		resizeTail int
+	}{
+		{2, []int{}, []int{}, []int{}, "", false, []int{}, 0, 2, 0},
+		{2, []int{0, 1}, []int{0}, []int{2}, "", true, []int{1, 2}, 0, 2, 2},
+		{2, []int{0, 1}, []int{0, 1}, []int{2, 3, 4}, "queue full: cannot enqueue 4", true, []int{2, 3}, 4, 4, 2},
+		{4, []int{0, 1, 2, 3}, []int{0, 1, 2}, []int{4, 5}, "", false, []int{3, 4, 5}, 2, 3, 3},
+	}
+	for i, test := range tests {
+		c := NewCircularOf[int](test.size)
+		for _, v := range test.items {
+			_ = c.Enqueue(v)
+		}
+		for j, v := range test.dequeue {
+			val, ok := c.Dequeue()
+			if !ok {
+				t.Errorf("%d dequeue #%d: expected ok to be true, got false", i, j)
+			}
+			if val != v {
+				t.Errorf("%d dequeue #%d: expected %d, got %d", i, j, v, val)
+			}
+		}
+		var err error
+		for _, v := range test.enqueue {
+			err = c.Enqueue(v)
+		}
+		if err != nil && err.Error() != test.err {
+			t.Errorf("%d: expected error to be %q, got %q", i, test.err, err)
+		}
+		if err == nil && test.err != "" {
+			t.Errorf("%d: expected error %q, got none", i, test.err)
+		}
+		if c.IsFull() != test.isFull {
+			t.Errorf("%d: expected IsFull to be %t, got %t", i, test.isFull, c.IsFull())
+		}
+		if c.Len() != len(test.remaining) {
+			t.Errorf("%d: expected len to be %d, got %d", i, len(test.remaining), c.Len())
+		}
+		if n := c.Resize(test.resize); n != test.resizeCap {
+			t.Errorf("%d: expected Resize to return %d, got %d", i, test.resizeCap, n)
+		}
+		if c.Cap() != test.resizeCap {
+			t.Errorf("%d: post resize, expected cap to be %d, got %d", i, test.resizeCap, c.Cap())
+		}
+		if c.Head != 0 || c.Tail != test.resizeTail {
+			t.Errorf("%d: post resize, expected head/tail to be 0/%d, got %d/%d", i, test.resizeTail, c.Head, c.Tail)
+		}
+		for j, v := range test.remaining {
+			val, ok := c.Dequeue()
+			if !ok || val != v {
+				t.Errorf("%d remaining #%d: expected %d, got %d (%t)", i, j, v, val, ok)
+			}
+		}
+		if _, ok := c.Dequeue(); ok {
+			t.Errorf("%d: expected the queue to be empty", i)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only changes when it is advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: make(chan time.Time, 1), at: c.now.Add(d)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward, firing the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.stopped() {
+			continue
+		}
+		if !t.at.After(c.now) {
+			t.fire(c.now)
+			continue
+		}
+		timers = append(timers, t)
+	}
+	c.timers = timers
+}
+
+// Timers returns the number of active timers.
+func (c *fakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for _, t := range c.timers {
+		if !t.stopped() {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeTimer struct {
+	mu   sync.Mutex
+	c    chan time.Time
+	at   time.Time
+	done bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := !t.done
+	t.done = true
+	return active
+}
+
+func (t *fakeTimer) stopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.done
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done = true
+	t.c <- now
+}
+
+func TestSystemClock(t *testing.T) {
+	var c Clock = systemClock{}
+	before := time.Now()
+	if now := c.Now(); now.Before(before) {
+		t.Errorf("expected Now to be >= %v, got %v", before, now)
+	}
+	timer := c.NewTimer(time.Millisecond)
+	<-timer.C()
+	if timer.Stop() {
+		t.Error("expected Stop on a fired timer to return false")
+	}
+}
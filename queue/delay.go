@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// delayItem is an item in a Delay queue.
+type delayItem struct {
+	value interface{}
+	due   time.Time
+	seq   uint64 // enqueue order; orders items that are due at the same time
+}
+
+// delayHeap is a min-heap of delayItems ordered by due time. It implements
+// heap.Interface.
+type delayHeap []delayItem
+
+func (h delayHeap) Len() int { return len(h) }
+
+func (h delayHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due.Before(h[j].due)
+}
+
+func (h delayHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *delayHeap) Push(x interface{}) { *h = append(*h, x.(delayItem)) }
+
+func (h *delayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = delayItem{}
+	*h = old[:n-1]
+	return item
+}
+
+// Delay is a thread-safe queue whose items only become visible once they
+// are due. Items are dequeued in order of their due time; items that are due
+// at the same time are dequeued in the order they were enqueued.
+type Delay struct {
+	mu      sync.Mutex
+	clock   Clock
+	items   delayHeap
+	seq     uint64
+	changed chan struct{} // closed, and replaced, when an item is enqueued
+}
+
+// NewDelay returns an empty Delay queue that uses the received clock. If
+// clock is nil, the system clock is used.
+func NewDelay(clock Clock) *Delay {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &Delay{clock: clock, changed: make(chan struct{})}
+}
+
+// EnqueueAt adds an item to the queue that becomes visible at the received
+// time.
+func (d *Delay) EnqueueAt(item interface{}, at time.Time) {
+	d.mu.Lock()
+	heap.Push(&d.items, delayItem{value: item, due: at, seq: d.seq})
+	d.seq++
+	// wake the waiters so they can check whether this is the new earliest
+	// item.
+	close(d.changed)
+	d.changed = make(chan struct{})
+	d.mu.Unlock()
+}
+
+// EnqueueAfter adds an item to the queue that becomes visible once the
+// received duration has elapsed.
+func (d *Delay) EnqueueAfter(item interface{}, delay time.Duration) {
+	d.EnqueueAt(item, d.clock.Now().Add(delay))
+}
+
+// Dequeue removes the earliest item that is due and returns it. If no item
+// is due, a false will be returned.
+func (d *Delay) Dequeue() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	item, ok, _ := d.dequeue()
+	return item, ok
+}
+
+// dequeue removes the earliest item if it is due. If it isn't, the time
+// until it is due is returned; if the queue is empty, that time is < 0. The
+// caller is expected to handle locking.
+func (d *Delay) dequeue() (interface{}, bool, time.Duration) {
+	if len(d.items) == 0 {
+		return nil, false, -1
+	}
+	if wait := d.items[0].due.Sub(d.clock.Now()); wait > 0 {
+		return nil, false, wait
+	}
+	return heap.Pop(&d.items).(delayItem).value, true, 0
+}
+
+// DequeueWait removes the earliest item that is due and returns it. If no
+// item is due, it sleeps until the earliest item is due, waking early if an
+// earlier item is enqueued. If ctx is done first, the context's error is
+// returned.
+func (d *Delay) DequeueWait(ctx context.Context) (interface{}, error) {
+	for {
+		d.mu.Lock()
+		item, ok, wait := d.dequeue()
+		changed := d.changed
+		d.mu.Unlock()
+		if ok {
+			return item, nil
+		}
+		var t Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			t = d.clock.NewTimer(wait)
+			timeout = t.C()
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-timeout:
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Next returns the time at which the earliest item is due. If the queue is
+// empty, a false will be returned.
+func (d *Delay) Next() (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.items) == 0 {
+		return time.Time{}, false
+	}
+	return d.items[0].due, true
+}
+
+// IsEmpty returns whether or not the queue is empty; items that are not yet
+// due are included.
+func (d *Delay) IsEmpty() bool {
+	return d.Len() == 0
+}
+
+// Len returns the number of items in the queue; items that are not yet due
+// are included.
+func (d *Delay) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
+
+// Reset removes all items from the queue.
+func (d *Delay) Reset() {
+	d.mu.Lock()
+	clear(d.items)
+	d.items = d.items[:0]
+	d.mu.Unlock()
+}
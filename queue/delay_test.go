@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	clock := newFakeClock()
+	d := NewDelay(clock)
+	d.EnqueueAfter("c", 3*time.Second)
+	d.EnqueueAfter("a", time.Second)
+	d.EnqueueAt("b", clock.Now().Add(time.Second))
+	d.EnqueueAfter("now", 0)
+	if d.Len() != 4 {
+		t.Errorf("expected len to be 4, got %d", d.Len())
+	}
+	tests := []struct {
+		advance  time.Duration
+		expected []interface{}
+	}{
+		{0, []interface{}{"now"}},
+		{500 * time.Millisecond, []interface{}{}},
+		{500 * time.Millisecond, []interface{}{"a", "b"}},
+		{time.Second, []interface{}{}},
+		{time.Second, []interface{}{"c"}},
+	}
+	for i, test := range tests {
+		clock.Advance(test.advance)
+		var got []interface{}
+		for {
+			v, ok := d.Dequeue()
+			if !ok {
+				break
+			}
+			got = append(got, v)
+		}
+		if len(got) != len(test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, got)
+			continue
+		}
+		for j, v := range test.expected {
+			if got[j] != v {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, v, got[j])
+			}
+		}
+	}
+	if !d.IsEmpty() {
+		t.Errorf("expected queue to be empty, len was %d", d.Len())
+	}
+	if _, ok := d.Next(); ok {
+		t.Error("expected Next on an empty queue to return false")
+	}
+}
+
+func TestDelayDequeueWait(t *testing.T) {
+	clock := newFakeClock()
+	d := NewDelay(clock)
+	d.EnqueueAfter("late", time.Minute)
+	got := make(chan interface{})
+	go func() {
+		for i := 0; i < 2; i++ {
+			v, err := d.DequeueWait(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %q", err)
+			}
+			got <- v
+		}
+	}()
+	waitTimers(t, clock, 1)
+	// an earlier item replaces the waiter's timer
+	d.EnqueueAfter("early", time.Second)
+	if next, _ := d.Next(); !next.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("expected next to be in 1s, got %v", next.Sub(clock.Now()))
+	}
+	waitTimers(t, clock, 1)
+	clock.Advance(time.Second)
+	if v := <-got; v != "early" {
+		t.Errorf("expected early, got %v", v)
+	}
+	waitTimers(t, clock, 1)
+	clock.Advance(time.Minute)
+	if v := <-got; v != "late" {
+		t.Errorf("expected late, got %v", v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.EnqueueAfter("never", time.Hour)
+	go func() {
+		for clock.Timers() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := d.DequeueWait(ctx); err != context.Canceled {
+		t.Errorf("expected %q, got %v", context.Canceled, err)
+	}
+	d.Reset()
+	if !d.IsEmpty() {
+		t.Errorf("after Reset(), expected queue to be empty, len was %d", d.Len())
+	}
+}
+
+// waitTimers waits until the clock has n active timers; i.e. until the
+// goroutines being tested are asleep.
+func waitTimers(t *testing.T, clock *fakeClock, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Timers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d timers, have %d", n, clock.Timers())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,198 @@
+package queue
+
+import "sync"
+
+// Deque is a thread-safe, double-ended queue: items can be added to, and
+// removed from, both its front and its back. It is implemented as a circular
+// buffer that grows as needed.
+type Deque struct {
+	mu      sync.Mutex
+	initCap int
+	items   []interface{}
+	head    int // index of the front item
+	n       int // number of items in the deque
+}
+
+// NewDeque returns an empty deque with an initial capacity equal to the
+// received size.
+func NewDeque(size int) *Deque {
+	if size < 1 {
+		size = 1
+	}
+	return &Deque{initCap: size, items: make([]interface{}, size)}
+}
+
+// PushFront adds an item to the front of the deque, growing the deque if
+// necessary.
+func (d *Deque) PushFront(item interface{}) {
+	d.mu.Lock()
+	if d.n == len(d.items) {
+		d.resize(len(d.items) * 2)
+	}
+	d.head = d.index(-1)
+	d.items[d.head] = item
+	d.n++
+	d.mu.Unlock()
+}
+
+// PushBack adds an item to the back of the deque, growing the deque if
+// necessary.
+func (d *Deque) PushBack(item interface{}) {
+	d.mu.Lock()
+	if d.n == len(d.items) {
+		d.resize(len(d.items) * 2)
+	}
+	d.items[d.index(d.n)] = item
+	d.n++
+	d.mu.Unlock()
+}
+
+// PopFront removes the item at the front of the deque and returns it. If
+// the deque is empty, a false will be returned.
+func (d *Deque) PopFront() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.n == 0 {
+		return nil, false
+	}
+	item := d.items[d.head]
+	d.items[d.head] = nil
+	d.head = d.index(1)
+	d.n--
+	return item, true
+}
+
+// PopBack removes the item at the back of the deque and returns it. If the
+// deque is empty, a false will be returned.
+func (d *Deque) PopBack() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.n == 0 {
+		return nil, false
+	}
+	i := d.index(d.n - 1)
+	item := d.items[i]
+	d.items[i] = nil
+	d.n--
+	return item, true
+}
+
+// PeekFront returns the item at the front of the deque without removing it.
+// If the deque is empty, a false will be returned.
+func (d *Deque) PeekFront() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.n == 0 {
+		return nil, false
+	}
+	return d.items[d.head], true
+}
+
+// PeekBack returns the item at the back of the deque without removing it.
+// If the deque is empty, a false will be returned.
+func (d *Deque) PeekBack() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.n == 0 {
+		return nil, false
+	}
+	return d.items[d.index(d.n-1)], true
+}
+
+// At returns the i-th item of the deque, where 0 is the front of the deque,
+// without removing it. If i is out of range, a false will be returned.
+func (d *Deque) At(i int) (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i < 0 || i >= d.n {
+		return nil, false
+	}
+	return d.items[d.index(i)], true
+}
+
+// IsEmpty returns whether or not the deque is empty.
+func (d *Deque) IsEmpty() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.n == 0
+}
+
+// IsFull returns false; a deque grows as needed so it is never full.
+func (d *Deque) IsFull() bool {
+	return false
+}
+
+// Len returns the current number of items in the deque.
+func (d *Deque) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.n
+}
+
+// Cap returns the current capacity of the deque.
+func (d *Deque) Cap() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
+
+// Reset resets the deque. This does not shrink the deque; for that use
+// Resize(). Any items in the deque will be lost.
+func (d *Deque) Reset() {
+	d.mu.Lock()
+	clear(d.items)
+	d.head = 0
+	d.n = 0
+	d.mu.Unlock()
+}
+
+// Resize resizes the deque to the received size, or, either its original
+// capacity or to 1.25 * the number of items in the deque, whichever is
+// larger. When a size of 0 is received, the deque will be set to either
+// 1.25 * the number of items in the deque or its initial capacity, whichever
+// is larger. Any items in the deque are copied, in order, to the front of
+// the new deque. The new capacity is returned.
+func (d *Deque) Resize(size int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := int(float64(d.n) * 1.25)
+	if i < d.n {
+		i = d.n
+	}
+	if i < d.initCap {
+		i = d.initCap
+	}
+	if size > i {
+		i = size
+	}
+	d.resize(i)
+	return i
+}
+
+// resize copies the items, in order, to a new slice with the received
+// capacity. The caller is expected to handle locking and to ensure that size
+// is >= the number of items.
+func (d *Deque) resize(size int) {
+	tmp := make([]interface{}, size)
+	if d.head+d.n <= len(d.items) {
+		copy(tmp, d.items[d.head:d.head+d.n])
+	} else {
+		n := copy(tmp, d.items[d.head:])
+		copy(tmp[n:], d.items[:d.n-n])
+	}
+	d.items = tmp
+	d.head = 0
+}
+
+// index returns the slice index of the i-th item from the front of the
+// deque; i may be -1 for the slot before the front.
+func (d *Deque) index(i int) int {
+	i += d.head
+	if i < 0 {
+		return i + len(d.items)
+	}
+	if i >= len(d.items) {
+		return i - len(d.items)
+	}
+	return i
+}
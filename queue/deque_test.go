@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestDeque(t *testing.T) {
+	tests := []struct {
+		size  int
+		front []int // pushed onto the front, in order
+		back  []int // pushed onto the back, in order
+		items []int // front to back
+		cap   int
+	}{
+		{2, []int{}, []int{}, []int{}, 2},
+		{2, []int{}, []int{0, 1}, []int{0, 1}, 2},
+		{2, []int{0, 1}, []int{}, []int{1, 0}, 2},
+		{2, []int{0, 1}, []int{2, 3}, []int{1, 0, 2, 3}, 4},
+		{2, []int{0, 1, 2}, []int{3, 4}, []int{2, 1, 0, 3, 4}, 8},
+		{0, []int{0}, []int{1}, []int{0, 1}, 2},
+	}
+	for i, test := range tests {
+		d := NewDeque(test.size)
+		for _, v := range test.front {
+			d.PushFront(v)
+		}
+		for _, v := range test.back {
+			d.PushBack(v)
+		}
+		if d.Len() != len(test.items) {
+			t.Errorf("%d: expected len to be %d, got %d", i, len(test.items), d.Len())
+		}
+		if d.Cap() != test.cap {
+			t.Errorf("%d: expected cap to be %d, got %d", i, test.cap, d.Cap())
+		}
+		for j, v := range test.items {
+			val, ok := d.At(j)
+			if !ok || val != v {
+				t.Errorf("%d: At(%d): expected %d, got %v (%t)", i, j, v, val, ok)
+			}
+		}
+		if _, ok := d.At(len(test.items)); ok {
+			t.Errorf("%d: expected At(%d) to be out of range", i, len(test.items))
+		}
+		if len(test.items) == 0 {
+			if !d.IsEmpty() {
+				t.Errorf("%d: expected deque to be empty", i)
+			}
+			if _, ok := d.PeekFront(); ok {
+				t.Errorf("%d: expected PeekFront on an empty deque to return false", i)
+			}
+			if _, ok := d.PopBack(); ok {
+				t.Errorf("%d: expected PopBack on an empty deque to return false", i)
+			}
+			continue
+		}
+		if v, _ := d.PeekFront(); v != test.items[0] {
+			t.Errorf("%d: expected PeekFront to return %d, got %v", i, test.items[0], v)
+		}
+		if v, _ := d.PeekBack(); v != test.items[len(test.items)-1] {
+			t.Errorf("%d: expected PeekBack to return %d, got %v", i, test.items[len(test.items)-1], v)
+		}
+		// pop alternately from the front and the back
+		lo, hi := 0, len(test.items)-1
+		for j := 0; lo <= hi; j++ {
+			if j%2 == 0 {
+				v, _ := d.PopFront()
+				if v != test.items[lo] {
+					t.Errorf("%d: PopFront: expected %d, got %v", i, test.items[lo], v)
+				}
+				lo++
+				continue
+			}
+			v, _ := d.PopBack()
+			if v != test.items[hi] {
+				t.Errorf("%d: PopBack: expected %d, got %v", i, test.items[hi], v)
+			}
+			hi--
+		}
+		if !d.IsEmpty() {
+			t.Errorf("%d: expected deque to be empty, len was %d", i, d.Len())
+		}
+	}
+}
+
+func TestDequeResetResize(t *testing.T) {
+	tests := []struct {
+		size        int
+		push        int
+		pop         int
+		resize      int
+		expectedCap int
+	}{
+		{4, 0, 0, 0, 4},
+		{4, 3, 0, 0, 4},
+		{2, 5, 0, 0, 6},
+		{2, 5, 5, 0, 2},
+		{2, 5, 1, 0, 5},
+		{2, 5, 1, 10, 10},
+		{2, 5, 0, 3, 6},
+	}
+	for i, test := range tests {
+		d := NewDeque(test.size)
+		// push onto the front so the items wrap around the buffer
+		for j := 0; j < test.push; j++ {
+			d.PushFront(j)
+		}
+		for j := 0; j < test.pop; j++ {
+			_, _ = d.PopBack()
+		}
+		if n := d.Resize(test.resize); n != test.expectedCap {
+			t.Errorf("%d: expected Resize to return %d, got %d", i, test.expectedCap, n)
+		}
+		if d.Cap() != test.expectedCap {
+			t.Errorf("%d: after Resize(), expected cap to be %d, got %d", i, test.expectedCap, d.Cap())
+		}
+		for j := test.pop; j < test.push; j++ {
+			v, _ := d.PopBack()
+			if v != j {
+				t.Errorf("%d: after Resize(), expected %d, got %v", i, j, v)
+			}
+		}
+		d.PushBack(1)
+		d.Reset()
+		if !d.IsEmpty() {
+			t.Errorf("%d: after Reset(), expected deque to be empty, len was %d", i, d.Len())
+		}
+		if d.Cap() != test.expectedCap {
+			t.Errorf("%d: after Reset(), expected cap to be %d, got %d", i, test.expectedCap, d.Cap())
+		}
+	}
+}
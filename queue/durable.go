@@ -0,0 +1,630 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mohae/firkin/codec"
+)
+
+const (
+	// DefaultSegmentSize is the size at which a Durable queue starts a new
+	// segment file, unless DurableOptions.SegmentSize is set.
+	DefaultSegmentSize = 64 << 20
+
+	segmentExt     = ".seg"
+	checkpointFile = "checkpoint"
+	headerSize     = 8  // record length + crc
+	checkpointSize = 20 // segment + offset + crc
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errBadRecord is returned when a record is incomplete or its CRC doesn't
+// match; e.g. because it was being written when the process crashed.
+var errBadRecord = errors.New("durable queue: bad record")
+
+// ErrCorrupt is reported by Err, after a Durable queue is opened, when a bad
+// record was found before the end of the queue and the records from it on
+// were discarded.
+var ErrCorrupt = errors.New("durable queue: corrupt records discarded")
+
+// DurableOptions configures a Durable queue. The zero value is usable.
+type DurableOptions struct {
+	// SegmentSize is the size, in bytes, at which a new segment file is
+	// started. A record is never split across segments, so a segment may
+	// exceed this by the size of its last record. If 0, DefaultSegmentSize
+	// is used.
+	SegmentSize int64
+	// Sync, when true, syncs a segment to disk after each enqueue and the
+	// checkpoint after each dequeue. Without it, the contents survive a
+	// process crash but may not survive an operating system crash.
+	Sync bool
+	// Codec converts items to and from their on disk representation. If
+	// nil, codec.Gob is used.
+	Codec codec.Codec
+}
+
+// Durable is an unbounded queue whose items are stored on disk. Items are
+// appended, as CRC checked records, to segment files in the queue's
+// directory; when a segment reaches the segment size, a new one is started.
+// The position of the next item to dequeue is kept in a separate checkpoint
+// file. Segments are deleted once all of their items have been dequeued.
+//
+// When a Durable queue is opened, the items that had not been dequeued are
+// recovered. A record that was only partially written, e.g. because of a
+// crash, is discarded along with everything after it in its segment. If the
+// bad record isn't in the last segment, which can happen after an operating
+// system crash when Sync isn't set, the following segments are discarded too
+// and Err reports ErrCorrupt; the queue can still be used.
+//
+// Dequeue and Peek cannot return an error; if an I/O error occurs, they
+// return false and the error is available from Err.
+type Durable struct {
+	mu        sync.Mutex
+	dir       string
+	opts      DurableOptions
+	n         int // number of items in the queue
+	writer    *os.File
+	writeSeg  uint64
+	writeOff  int64
+	reader    *os.File
+	readSeg   uint64
+	readOff   int64
+	readSize  int64 // size of the read segment, when it isn't the write segment
+	checkpt   *os.File
+	slot      int64 // the checkpoint slot to write next
+	peeked    interface{}
+	peekNext  int64 // offset of the record following the peeked item
+	hasPeeked bool
+	err       error
+	closed    bool
+}
+
+// NewDurable opens the Durable queue stored in dir, creating the directory
+// and the queue if they don't exist, and recovers its contents. If opts is
+// nil, the default options are used.
+func NewDurable(dir string, opts *DurableOptions) (*Durable, error) {
+	d := &Durable{dir: dir}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.SegmentSize <= 0 {
+		d.opts.SegmentSize = DefaultSegmentSize
+	}
+	if d.opts.Codec == nil {
+		d.opts.Codec = codec.Gob{}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := d.recover(); err != nil {
+		d.closeFiles()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Enqueue appends an item to the queue's current segment.
+func (d *Durable) Enqueue(item interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	data, err := d.opts.Codec.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if d.writeOff > 0 && d.writeOff+headerSize+int64(len(data)) > d.opts.SegmentSize {
+		if err := d.roll(); err != nil {
+			return err
+		}
+	}
+	rec := make([]byte, headerSize+len(data))
+	binary.LittleEndian.PutUint32(rec, uint32(len(data)))
+	copy(rec[headerSize:], data)
+	binary.LittleEndian.PutUint32(rec[4:], recordCRC(rec[:4], data))
+	if _, err := d.writer.Write(rec); err != nil {
+		// don't leave a partial record for the next one to follow
+		_ = d.writer.Truncate(d.writeOff)
+		_, _ = d.writer.Seek(d.writeOff, io.SeekStart)
+		return err
+	}
+	if d.opts.Sync {
+		if err := d.writer.Sync(); err != nil {
+			return err
+		}
+	}
+	d.writeOff += int64(len(rec))
+	d.n++
+	return nil
+}
+
+// Dequeue removes the next item from the queue and returns it. If the queue
+// is empty, or an error occurs, a false will be returned; use Err to
+// distinguish between the two.
+func (d *Durable) Dequeue() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	item, next, ok := d.peek()
+	if !ok {
+		return nil, false
+	}
+	if err := d.writeCheckpoint(d.readSeg, next); err != nil {
+		d.err = err
+		return nil, false
+	}
+	d.readOff = next
+	d.hasPeeked = false
+	d.peeked = nil
+	d.n--
+	if d.readSeg < d.writeSeg && d.readOff >= d.readSize {
+		if err := d.advance(); err != nil {
+			d.err = err
+		}
+	}
+	return item, true
+}
+
+// Peek returns the next item in the queue without removing it. If the queue
+// is empty, or an error occurs, a false will be returned; use Err to
+// distinguish between the two.
+func (d *Durable) Peek() (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	item, _, ok := d.peek()
+	return item, ok
+}
+
+// peek reads the next item and returns it along with the offset of the
+// record that follows it. The caller is expected to handle locking.
+func (d *Durable) peek() (interface{}, int64, bool) {
+	if d.closed || d.n == 0 {
+		return nil, 0, false
+	}
+	if d.hasPeeked {
+		return d.peeked, d.peekNext, true
+	}
+	data, err := readRecord(d.reader, d.readOff, d.readEnd())
+	for err == io.EOF && d.readSeg < d.writeSeg {
+		// the rest of the items are in the following segments
+		if err = d.advance(); err == nil {
+			data, err = readRecord(d.reader, d.readOff, d.readEnd())
+		}
+	}
+	if err != nil {
+		d.err = err
+		return nil, 0, false
+	}
+	item, err := d.opts.Codec.Unmarshal(data)
+	if err != nil {
+		d.err = err
+		return nil, 0, false
+	}
+	d.peeked, d.hasPeeked = item, true
+	d.peekNext = d.readOff + headerSize + int64(len(data))
+	return item, d.peekNext, true
+}
+
+// readEnd returns the size of the segment being read. The caller is expected
+// to handle locking.
+func (d *Durable) readEnd() int64 {
+	if d.readSeg == d.writeSeg {
+		return d.writeOff
+	}
+	return d.readSize
+}
+
+// Err returns the last error that occurred during a Dequeue or Peek, if
+// any, or, if there was none, ErrCorrupt if records were discarded when the
+// queue was opened.
+func (d *Durable) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// IsEmpty returns whether or not the queue is empty.
+func (d *Durable) IsEmpty() bool {
+	return d.Len() == 0
+}
+
+// IsFull returns false; a Durable queue is only limited by the available
+// disk space.
+func (d *Durable) IsFull() bool {
+	return false
+}
+
+// Len returns the number of items in the queue.
+func (d *Durable) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.n
+}
+
+// Cap returns the number of items in the queue; a Durable queue grows with
+// its contents.
+func (d *Durable) Cap() int {
+	return d.Len()
+}
+
+// Reset removes all items from the queue. All segments but the current one
+// are deleted.
+func (d *Durable) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.hasPeeked = false
+	d.peeked = nil
+	for d.readSeg < d.writeSeg {
+		if err := d.advance(); err != nil {
+			d.err = err
+			return
+		}
+	}
+	if err := d.writeCheckpoint(d.writeSeg, d.writeOff); err != nil {
+		d.err = err
+		return
+	}
+	d.readOff = d.writeOff
+	d.n = 0
+}
+
+// Resize is implemented to fulfill Queuer. A Durable queue grows with its
+// contents so it is never resized; Cap() is returned.
+func (d *Durable) Resize(size int) int {
+	return d.Cap()
+}
+
+// Close closes the queue's files. The items that have not been dequeued
+// remain on disk and are recovered by the next NewDurable for the same
+// directory. Closing an already closed queue returns ErrClosed.
+func (d *Durable) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.closed = true
+	return d.closeFiles()
+}
+
+// closeFiles closes all of the open files, returning the first error.
+func (d *Durable) closeFiles() error {
+	files := []*os.File{d.writer, d.checkpt}
+	if d.reader != d.writer {
+		files = append(files, d.reader)
+	}
+	var err error
+	for _, f := range files {
+		if f == nil {
+			continue
+		}
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// roll starts a new segment for writing. The caller is expected to handle
+// locking.
+func (d *Durable) roll() error {
+	f, err := os.OpenFile(d.segmentPath(d.writeSeg+1), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if d.opts.Sync {
+		if err := d.writer.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if d.readSeg == d.writeSeg {
+		d.readSize = d.writeOff
+	}
+	if d.writer != d.reader {
+		d.writer.Close()
+	}
+	d.writer = f
+	d.writeSeg++
+	d.writeOff = 0
+	return nil
+}
+
+// advance moves the reader to the next segment and deletes the segment it
+// was reading. The checkpoint is moved before the segment is deleted so
+// that a crash in between leaves, at worst, an unused segment behind. The
+// caller is expected to handle locking.
+func (d *Durable) advance() error {
+	next := d.readSeg + 1
+	if err := d.writeCheckpoint(next, 0); err != nil {
+		return err
+	}
+	var f *os.File
+	if next == d.writeSeg {
+		f = d.writer
+	} else {
+		var err error
+		f, err = os.Open(d.segmentPath(next))
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		d.readSize = fi.Size()
+	}
+	d.reader.Close()
+	if err := os.Remove(d.segmentPath(d.readSeg)); err != nil {
+		f.Close()
+		return err
+	}
+	d.reader = f
+	d.readSeg = next
+	d.readOff = 0
+	return nil
+}
+
+// recover opens the queue's files, discarding consumed segments and any
+// partially written record at the end of the last segment, and counts the
+// items that have not been dequeued.
+func (d *Durable) recover() error {
+	var err error
+	d.checkpt, err = os.OpenFile(filepath.Join(d.dir, checkpointFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	d.readSeg, d.readOff, err = d.readCheckpoint()
+	if err != nil {
+		return err
+	}
+	checkptOff := d.readOff
+	segs, err := d.segments()
+	if err != nil {
+		return err
+	}
+	var live []uint64
+	for _, seg := range segs {
+		if seg < d.readSeg {
+			if err := os.Remove(d.segmentPath(seg)); err != nil {
+				return err
+			}
+			continue
+		}
+		live = append(live, seg)
+	}
+	if len(live) == 0 || live[0] != d.readSeg {
+		// nothing left to read: start over at the checkpointed segment
+		d.readOff = 0
+		live = append([]uint64{d.readSeg}, live...)
+	}
+	for i, seg := range live {
+		if i > 0 && seg != live[i-1]+1 {
+			return fmt.Errorf("durable queue: segment %d is missing", live[i-1]+1)
+		}
+		last := i == len(live)-1
+		f, err := os.OpenFile(d.segmentPath(seg), os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		off := int64(0)
+		if seg == d.readSeg {
+			// the checkpoint can be ahead of unsynced writes that were
+			// lost in an operating system crash
+			d.readOff = min(d.readOff, fi.Size())
+			d.readSize = fi.Size()
+			d.reader = f
+			off = d.readOff
+		}
+		end, n, err := scanSegment(f, off, fi.Size())
+		if err != nil && err != errBadRecord {
+			f.Close()
+			return fmt.Errorf("durable queue: segment %d: %w", seg, err)
+		}
+		d.n += n
+		if err != nil && !last {
+			// a record was lost, e.g. in an operating system crash without
+			// Sync: the queue ends at the last good record before it, so the
+			// segments that follow, written after it, are dropped
+			for _, s := range live[i+1:] {
+				if err := os.Remove(d.segmentPath(s)); err != nil {
+					f.Close()
+					return err
+				}
+			}
+			d.err = fmt.Errorf("durable queue: segment %d: discarded the records from offset %d on: %w", seg, end, ErrCorrupt)
+			last = true
+		}
+		if !last {
+			if seg != d.readSeg {
+				f.Close()
+			}
+			continue
+		}
+		// discard anything after the last good record; e.g. a torn write
+		if err := f.Truncate(end); err != nil {
+			return err
+		}
+		if _, err := f.Seek(end, io.SeekStart); err != nil {
+			return err
+		}
+		d.writer = f
+		d.writeSeg = seg
+		d.writeOff = end
+		if d.readOff != checkptOff {
+			return d.resetCheckpoint(d.readSeg, d.readOff)
+		}
+		return nil
+	}
+	return nil
+}
+
+// segments returns the numbers of the segment files in the queue's
+// directory, in ascending order.
+func (d *Durable) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segs = append(segs, n)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+func (d *Durable) segmentPath(seg uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%020d%s", seg, segmentExt))
+}
+
+// The checkpoint file has two slots, each holding a segment number, an
+// offset, and a CRC. Writes alternate between the slots so that a torn
+// write never destroys the last good checkpoint; when reading, the valid
+// slot with the furthest position wins since consumption only moves
+// forward.
+
+// writeCheckpoint records the position of the next item to be dequeued.
+// The caller is expected to handle locking.
+func (d *Durable) writeCheckpoint(seg uint64, off int64) error {
+	var b [checkpointSize]byte
+	binary.LittleEndian.PutUint64(b[0:], seg)
+	binary.LittleEndian.PutUint64(b[8:], uint64(off))
+	binary.LittleEndian.PutUint32(b[16:], crc32.Checksum(b[:16], crcTable))
+	if _, err := d.checkpt.WriteAt(b[:], d.slot*checkpointSize); err != nil {
+		return err
+	}
+	d.slot ^= 1
+	if d.opts.Sync {
+		return d.checkpt.Sync()
+	}
+	return nil
+}
+
+// resetCheckpoint writes the position to both checkpoint slots and syncs
+// them. It is used when recover moves the position back, e.g. because the
+// checkpointed segment is missing or shorter than the checkpointed offset:
+// otherwise, the slot holding the further ahead position would win the next
+// time the queue is opened and the items enqueued since would be skipped.
+// The caller is expected to handle locking.
+func (d *Durable) resetCheckpoint(seg uint64, off int64) error {
+	for range 2 {
+		if err := d.writeCheckpoint(seg, off); err != nil {
+			return err
+		}
+	}
+	return d.checkpt.Sync()
+}
+
+// readCheckpoint returns the position of the next item to be dequeued and
+// sets the slot to write next. A queue without a checkpoint starts at the
+// beginning of segment 1.
+func (d *Durable) readCheckpoint() (uint64, int64, error) {
+	var b [2 * checkpointSize]byte
+	n, err := d.checkpt.ReadAt(b[:], 0)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	seg, off := uint64(1), int64(0)
+	for slot := 0; (slot+1)*checkpointSize <= n; slot++ {
+		s := b[slot*checkpointSize : (slot+1)*checkpointSize]
+		if binary.LittleEndian.Uint32(s[16:]) != crc32.Checksum(s[:16], crcTable) {
+			continue
+		}
+		sg, o := binary.LittleEndian.Uint64(s), int64(binary.LittleEndian.Uint64(s[8:]))
+		if sg == 0 {
+			continue
+		}
+		if sg > seg || (sg == seg && o > off) {
+			seg, off = sg, o
+			// don't overwrite the newest checkpoint with the next one
+			d.slot = int64(slot ^ 1)
+		}
+	}
+	return seg, off, nil
+}
+
+// readRecord reads the record at off, in a segment of the received size, and
+// returns its data. io.EOF is returned if there is no record at off. The
+// length in the header is checked against the size of the segment before
+// the data is read, so that a corrupted length can't cause a huge
+// allocation.
+func readRecord(f *os.File, off, size int64) ([]byte, error) {
+	var hdr [headerSize]byte
+	n, err := f.ReadAt(hdr[:], off)
+	if n == 0 && err == io.EOF {
+		return nil, io.EOF
+	}
+	if n < headerSize {
+		return nil, errBadRecord
+	}
+	l := binary.LittleEndian.Uint32(hdr[:])
+	if off+headerSize+int64(l) > size {
+		return nil, errBadRecord
+	}
+	data := make([]byte, l)
+	if _, err := f.ReadAt(data, off+headerSize); err != nil {
+		if err == io.EOF {
+			return nil, errBadRecord
+		}
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(hdr[4:]) != recordCRC(hdr[:4], data) {
+		return nil, errBadRecord
+	}
+	return data, nil
+}
+
+// scanSegment validates the records in f, a segment of the received size,
+// starting at off. It returns the offset following the last good record and
+// the number of good records. If it stopped at a bad record, errBadRecord is
+// returned.
+func scanSegment(f *os.File, off, size int64) (int64, int, error) {
+	var n int
+	for {
+		data, err := readRecord(f, off, size)
+		if err == io.EOF {
+			return off, n, nil
+		}
+		if err != nil {
+			return off, n, err
+		}
+		off += headerSize + int64(len(data))
+		n++
+	}
+}
+
+// recordCRC returns the CRC of a record. The length is included so that a
+// zero-filled header is never a valid record.
+func recordCRC(length, data []byte) uint32 {
+	crc := crc32.Update(0, crcTable, length)
+	return crc32.Update(crc, crcTable, data)
+}
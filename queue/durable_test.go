@@ -0,0 +1,334 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ Queuer = (*Durable)(nil)
+
+func TestDurable(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, &DurableOptions{SegmentSize: 128})
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	if _, ok := d.Dequeue(); ok {
+		t.Error("expected dequeue on an empty queue to return false")
+	}
+	for i := 0; i < 20; i++ {
+		if err := d.Enqueue(i); err != nil {
+			t.Fatalf("enqueue %d: unexpected error: %q", i, err)
+		}
+	}
+	if d.Len() != 20 {
+		t.Errorf("expected len to be 20, got %d", d.Len())
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) < 2 {
+		t.Fatalf("expected the items to span several segments, got %d", len(segs))
+	}
+	v, ok := d.Peek()
+	if !ok || v != 0 {
+		t.Errorf("expected peek to return 0, got %v (%t)", v, ok)
+	}
+	for i := 0; i < 12; i++ {
+		v, ok := d.Dequeue()
+		if !ok || v != i {
+			t.Fatalf("expected %d, got %v (%t): %v", i, v, ok, d.Err())
+		}
+	}
+	remaining, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(remaining) >= len(segs) {
+		t.Errorf("expected consumed segments to be deleted; had %d, have %d", len(segs), len(remaining))
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("close: unexpected error: %q", err)
+	}
+	if err := d.Enqueue(1); err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+
+	// reopen: only the items that weren't dequeued are recovered
+	d, err = NewDurable(dir, &DurableOptions{SegmentSize: 128})
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	if d.Len() != 8 {
+		t.Errorf("after reopen, expected len to be 8, got %d", d.Len())
+	}
+	_ = d.Enqueue("last")
+	for i := 12; i < 20; i++ {
+		v, ok := d.Dequeue()
+		if !ok || v != i {
+			t.Fatalf("after reopen, expected %d, got %v (%t): %v", i, v, ok, d.Err())
+		}
+	}
+	if v, _ := d.Dequeue(); v != "last" {
+		t.Errorf("after reopen, expected last, got %v", v)
+	}
+	if !d.IsEmpty() {
+		t.Errorf("expected queue to be empty, len was %d", d.Len())
+	}
+	d.Close()
+}
+
+func TestDurableTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	for i := 0; i < 3; i++ {
+		_ = d.Enqueue(i)
+	}
+	_, _ = d.Dequeue()
+	d.Close()
+
+	// simulate a crash in the middle of writing a record
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	f, err := os.OpenFile(segs[len(segs)-1], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	_, _ = f.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, 5})
+	f.Close()
+
+	d, err = NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	if d.Len() != 2 {
+		t.Errorf("expected len to be 2, got %d", d.Len())
+	}
+	_ = d.Enqueue(3)
+	for i := 1; i < 4; i++ {
+		v, ok := d.Dequeue()
+		if !ok || v != i {
+			t.Errorf("expected %d, got %v (%t): %v", i, v, ok, d.Err())
+		}
+	}
+	d.Close()
+}
+
+func TestDurableReset(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, &DurableOptions{SegmentSize: 64, Sync: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	for i := 0; i < 10; i++ {
+		_ = d.Enqueue(i)
+	}
+	d.Reset()
+	if !d.IsEmpty() {
+		t.Errorf("after Reset(), expected queue to be empty, len was %d", d.Len())
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) != 1 {
+		t.Errorf("after Reset(), expected 1 segment, got %d", len(segs))
+	}
+	_ = d.Enqueue("a")
+	d.Close()
+	d, err = NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	defer d.Close()
+	if v, ok := d.Dequeue(); !ok || v != "a" || !d.IsEmpty() {
+		t.Errorf("after reopen, expected only a, got %v (%t) and len %d", v, ok, d.Len())
+	}
+}
+
+func TestDurableCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	_ = d.Enqueue(0)
+	d.Close()
+
+	// a header whose length is far larger than the segment is a bad record;
+	// it must not be allocated
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	f, err := os.OpenFile(segs[0], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	_, _ = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 1, 2, 3, 4})
+	f.Close()
+	sf := mustOpen(t, segs[0])
+	fi, _ := sf.Stat()
+	if _, err := readRecord(sf, fi.Size()-headerSize, fi.Size()); err != errBadRecord {
+		t.Errorf("expected errBadRecord, got %v", err)
+	}
+
+	d, err = NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	defer d.Close()
+	if v, ok := d.Dequeue(); !ok || v != 0 || !d.IsEmpty() {
+		t.Errorf("expected only 0, got %v (%t) and len %d", v, ok, d.Len())
+	}
+}
+
+func mustOpen(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestDurableCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	opts := &DurableOptions{SegmentSize: 128}
+	d, err := NewDurable(dir, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	for i := 0; i < 20; i++ {
+		_ = d.Enqueue(i)
+	}
+	d.Close()
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) < 3 {
+		t.Fatalf("expected the items to span at least 3 segments, got %d", len(segs))
+	}
+
+	// corrupt the last byte of the second segment, e.g. an unsynced write
+	// lost in an operating system crash
+	b, err := os.ReadFile(segs[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	b[len(b)-1] ^= 0xff
+	if err := os.WriteFile(segs[1], b, 0o644); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	n0, n1 := countRecords(t, segs[0]), countRecords(t, segs[1])
+
+	// the queue can still be opened, ending before the bad record
+	d, err = NewDurable(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	defer d.Close()
+	if !errors.Is(d.Err(), ErrCorrupt) {
+		t.Errorf("expected %q, got %v", ErrCorrupt, d.Err())
+	}
+	if d.Len() != n0+n1 {
+		t.Errorf("expected len %d, got %d", n0+n1, d.Len())
+	}
+	remaining, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(remaining) != 2 {
+		t.Errorf("expected the segments after the bad record to be removed, have %d", len(remaining))
+	}
+	_ = d.Enqueue("next")
+	for i := 0; i < n0+n1; i++ {
+		if v, ok := d.Dequeue(); !ok || v != i {
+			t.Fatalf("expected %d, got %v (%t)", i, v, ok)
+		}
+	}
+	if v, _ := d.Dequeue(); v != "next" {
+		t.Errorf("expected next, got %v", v)
+	}
+}
+
+func TestDurableStaleCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	for i := 0; i < 10; i++ {
+		_ = d.Enqueue(i)
+	}
+	for i := 0; i < 8; i++ {
+		_, _ = d.Dequeue()
+	}
+	d.Close()
+
+	// the segment loses writes the checkpoint had moved past, e.g. in an
+	// operating system crash without Sync
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	fi, err := os.Stat(segs[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	if err := os.Truncate(segs[0], fi.Size()/3); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	d, err = NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	if !d.IsEmpty() {
+		t.Errorf("expected the items before the checkpoint to stay dequeued, len was %d", d.Len())
+	}
+	for i := 0; i < 20; i++ {
+		_ = d.Enqueue(i)
+	}
+	_, _ = d.Dequeue()
+	d.Close()
+
+	// the position recover moved back to, not the stale one, is used
+	d, err = NewDurable(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: unexpected error: %q", err)
+	}
+	defer d.Close()
+	if d.Len() != 19 || d.Err() != nil {
+		t.Errorf("expected len 19, got %d: %v", d.Len(), d.Err())
+	}
+	if v, ok := d.Dequeue(); !ok || v != 1 {
+		t.Errorf("expected 1, got %v (%t)", v, ok)
+	}
+}
+
+// countRecords returns the number of good records in the segment.
+func countRecords(t *testing.T, name string) int {
+	t.Helper()
+	f := mustOpen(t, name)
+	fi, _ := f.Stat()
+	_, n, _ := scanSegment(f, 0, fi.Size())
+	return n
+}
+
+// bracketCodec is a custom codec.Codec that stores strings as is and
+// brackets them when they are decoded.
+type bracketCodec struct{}
+
+func (bracketCodec) Marshal(item interface{}) ([]byte, error) {
+	return []byte(item.(string)), nil
+}
+
+func (bracketCodec) Unmarshal(data []byte) (interface{}, error) {
+	return "<" + string(data) + ">", nil
+}
+
+func TestDurableCodec(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurable(dir, &DurableOptions{Codec: bracketCodec{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	_ = d.Enqueue("a")
+	_ = d.Enqueue("b")
+	if v, ok := d.Dequeue(); !ok || v != "<a>" {
+		t.Errorf("expected <a>, got %v (%t): %v", v, ok, d.Err())
+	}
+	d.Close()
+	// the records are stored as the codec encoded them
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	f := mustOpen(t, segs[0])
+	if data, err := readRecord(f, headerSize+1, headerSize*2+2); err != nil || string(data) != "b" {
+		t.Errorf("expected the record to be b, got %q: %v", data, err)
+	}
+}
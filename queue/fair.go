@@ -0,0 +1,384 @@
+package queue
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Flowed is an item that belongs to a flow. Enqueueing a Flowed item onto a
+// Fair queue adds its Value to the queue of its Flow.
+type Flowed struct {
+	Flow  string
+	Value interface{}
+}
+
+// flow is a Fair queue's sub-queue for a flow key.
+type flow struct {
+	key       string
+	q         *Queue
+	deficit   int
+	credited  bool      // whether the flow has received its quantum this turn
+	idleSince time.Time // when the flow became empty
+	idleGen   uint64    // incremented each time the flow becomes empty
+}
+
+// idleFlow is an entry in a Fair queue's idle list. An entry whose gen is
+// not the flow's idleGen is stale: the flow has become empty again since,
+// and has a newer entry.
+type idleFlow struct {
+	fl  *flow
+	gen uint64
+}
+
+// Fair is a thread-safe queue that is shared fairly by flows, e.g. tenants,
+// so that one flow filling the queue doesn't delay the others. Each flow
+// has its own FIFO Queue; the flows are dequeued in turn using deficit round
+// robin: on its turn, a flow's deficit is credited with the quantum times
+// its weight and the flow's items are dequeued while their cost, 1 unless
+// a cost func is set, is covered by its deficit. Over time, each flow that
+// has items gets a share of the dequeued cost proportional to its weight.
+//
+// Flows are created when an item is first enqueued for them. A flow that has
+// been empty for longer than the idle timeout is removed; its weight, if it
+// was set, is kept.
+type Fair struct {
+	mu      sync.Mutex
+	quantum int
+	initCap int
+	flows   map[string]*flow
+	weights map[string]int
+	active  []*flow    // the flows with items, in round robin order
+	cur     int        // the index, in active, of the flow whose turn it is
+	idle    []idleFlow // the empty flows, in the order they became empty
+	timeout time.Duration
+	clock   Clock
+	cost    func(item interface{}) int
+	flowFn  func(item interface{}) string
+	n       int
+}
+
+// NewFair returns a fair queue that credits each flow with quantum times its
+// weight on its turn; quantum is at least 1. Each flow's queue is created
+// with an initial capacity of initCap. By default, empty flows are removed
+// immediately.
+func NewFair(quantum, initCap int) *Fair {
+	return &Fair{
+		quantum: max(quantum, 1),
+		initCap: initCap,
+		flows:   make(map[string]*flow),
+		weights: make(map[string]int),
+		clock:   systemClock{},
+	}
+}
+
+// SetWeight sets the weight of the flow; the weight is at least 1, which is
+// the default weight.
+func (f *Fair) SetWeight(key string, weight int) {
+	f.mu.Lock()
+	f.weights[key] = max(weight, 1)
+	f.mu.Unlock()
+}
+
+// Weight returns the weight of the flow.
+func (f *Fair) Weight(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.weight(key)
+}
+
+// weight is an unexported version that expects the caller to handle locking.
+func (f *Fair) weight(key string) int {
+	if w, ok := f.weights[key]; ok {
+		return w
+	}
+	return 1
+}
+
+// SetCostFunc sets the function that returns the cost of an item, e.g. its
+// size in bytes; a cost is at least 1. By default, each item costs 1.
+func (f *Fair) SetCostFunc(cost func(item interface{}) int) {
+	f.mu.Lock()
+	f.cost = cost
+	f.mu.Unlock()
+}
+
+// SetFlowFunc sets the function that Enqueue uses to get the flow of items
+// that are not Flowed.
+func (f *Fair) SetFlowFunc(fn func(item interface{}) string) {
+	f.mu.Lock()
+	f.flowFn = fn
+	f.mu.Unlock()
+}
+
+// SetIdleTimeout sets how long a flow is kept after it becomes empty.
+func (f *Fair) SetIdleTimeout(d time.Duration) {
+	f.mu.Lock()
+	f.timeout = d
+	f.mu.Unlock()
+}
+
+// SetClock sets the clock used to measure how long flows have been idle.
+func (f *Fair) SetClock(c Clock) {
+	f.mu.Lock()
+	f.clock = c
+	f.mu.Unlock()
+}
+
+// Enqueue adds an item to the queue of its flow. The flow of a Flowed item
+// is its Flow; the flow of any other item is provided by the flow func, see
+// SetFlowFunc. If there is no flow func, an error is returned.
+func (f *Fair) Enqueue(item interface{}) error {
+	if fl, ok := item.(Flowed); ok {
+		return f.EnqueueFlow(fl.Flow, fl.Value)
+	}
+	f.mu.Lock()
+	fn := f.flowFn
+	f.mu.Unlock()
+	if fn == nil {
+		return fmt.Errorf("cannot enqueue %v: no flow", item)
+	}
+	return f.EnqueueFlow(fn(item), item)
+}
+
+// EnqueueFlow adds an item to the queue of the flow, creating the flow if
+// it doesn't exist.
+func (f *Fair) EnqueueFlow(key string, item interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeIdle()
+	fl, ok := f.flows[key]
+	if !ok {
+		fl = &flow{key: key, q: NewQueue(f.initCap)}
+		f.flows[key] = fl
+	}
+	if err := fl.q.Enqueue(item); err != nil {
+		return err
+	}
+	if fl.q.Len() == 1 {
+		fl.idleSince = time.Time{}
+		f.active = append(f.active, fl)
+	}
+	f.n++
+	return nil
+}
+
+// Dequeue removes the next item, of the flow whose turn it is, and returns
+// it. If the queue is empty, a false will be returned.
+func (f *Fair) Dequeue() (interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeIdle()
+	fl := f.next()
+	if fl == nil {
+		return nil, false
+	}
+	item, _ := fl.q.Dequeue()
+	fl.deficit -= f.itemCost(item)
+	f.n--
+	if fl.q.IsEmpty() {
+		// an empty flow loses its deficit and its place in the round
+		fl.deficit = 0
+		fl.credited = false
+		f.active = slices.Delete(f.active, f.cur, f.cur+1)
+		if f.cur == len(f.active) {
+			f.cur = 0
+		}
+		f.idled(fl)
+	}
+	return item, true
+}
+
+// Peek returns the next item to be dequeued without removing it. If the
+// queue is empty, a false will be returned.
+func (f *Fair) Peek() (interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fl := f.next()
+	if fl == nil {
+		return nil, false
+	}
+	return fl.q.Peek()
+}
+
+// next returns the flow whose next item is to be dequeued, crediting the
+// flows' deficits and moving the turn along, or nil if there are no items.
+// The caller is expected to handle locking.
+func (f *Fair) next() *flow {
+	if len(f.active) == 0 {
+		return nil
+	}
+	for range f.active {
+		fl := f.active[f.cur]
+		if !fl.credited {
+			fl.deficit += f.quantum * f.weight(fl.key)
+			fl.credited = true
+		}
+		if f.nextCost(fl) <= fl.deficit {
+			return fl
+		}
+		// the rest of the deficit is kept for the flow's next turn
+		fl.credited = false
+		f.cur = (f.cur + 1) % len(f.active)
+	}
+	// No flow could dequeue its next item this round. Rather than going
+	// around until one can, which takes as many rounds as the cost is larger
+	// than the quantum, the rounds each flow needs are computed: the first
+	// flow, in turn order, that needs the fewest rounds is next. The flows
+	// before it are credited for that many rounds, the flows after it for
+	// one less.
+	var rounds, win int
+	for i := range f.active {
+		fl := f.active[(f.cur+i)%len(f.active)]
+		q := f.quantum * f.weight(fl.key)
+		r := (f.nextCost(fl) - fl.deficit + q - 1) / q
+		if i == 0 || r < rounds {
+			rounds, win = r, i
+		}
+	}
+	for i := range f.active {
+		fl := f.active[(f.cur+i)%len(f.active)]
+		r := rounds
+		if i > win {
+			r--
+		}
+		fl.deficit += r * f.quantum * f.weight(fl.key)
+	}
+	f.cur = (f.cur + win) % len(f.active)
+	fl := f.active[f.cur]
+	fl.credited = true
+	return fl
+}
+
+// nextCost returns the cost of the flow's next item. The caller is expected
+// to handle locking.
+func (f *Fair) nextCost(fl *flow) int {
+	item, _ := fl.q.Peek()
+	return f.itemCost(item)
+}
+
+// itemCost returns the cost of the item. The caller is expected to handle
+// locking.
+func (f *Fair) itemCost(item interface{}) int {
+	if f.cost == nil {
+		return 1
+	}
+	return max(f.cost(item), 1)
+}
+
+// idled records that the flow became empty, or removes it if there is no
+// idle timeout. The caller is expected to handle locking.
+func (f *Fair) idled(fl *flow) {
+	if f.timeout <= 0 {
+		delete(f.flows, fl.key)
+		return
+	}
+	fl.idleSince = f.clock.Now()
+	fl.idleGen++
+	f.idle = append(f.idle, idleFlow{fl: fl, gen: fl.idleGen})
+}
+
+// removeIdle removes the flows that have been empty for longer than the idle
+// timeout. As flows become empty in order, only the front of the idle list
+// needs to be checked, skipping the stale entries of flows that have items
+// again or have become empty again since. The caller is expected to handle
+// locking.
+func (f *Fair) removeIdle() {
+	if len(f.idle) == 0 {
+		return
+	}
+	now := f.clock.Now()
+	var i int
+	for ; i < len(f.idle); i++ {
+		fl := f.idle[i].fl
+		if fl.idleSince.IsZero() || fl.idleGen != f.idle[i].gen || f.flows[fl.key] != fl {
+			continue // it has items again, is newer, or was already removed
+		}
+		if now.Sub(fl.idleSince) < f.timeout {
+			break
+		}
+		delete(f.flows, fl.key)
+	}
+	clear(f.idle[:i])
+	f.idle = f.idle[i:]
+}
+
+// Flows returns the number of flows, including idle flows that haven't been
+// removed yet.
+func (f *Fair) Flows() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeIdle()
+	return len(f.flows)
+}
+
+// FlowLen returns the number of items in the flow's queue; 0 if there is no
+// such flow.
+func (f *Fair) FlowLen(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fl, ok := f.flows[key]
+	if !ok {
+		return 0
+	}
+	return fl.q.Len()
+}
+
+// IsEmpty returns whether or not the queue is empty.
+func (f *Fair) IsEmpty() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n == 0
+}
+
+// IsFull returns false: a fair queue is unbounded.
+func (f *Fair) IsFull() bool {
+	return false
+}
+
+// Len returns the number of items in the queue, across all flows.
+func (f *Fair) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// Cap returns the sum of the capacities of the flows' queues.
+func (f *Fair) Cap() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var c int
+	for _, fl := range f.flows {
+		c += fl.q.Cap()
+	}
+	return c
+}
+
+// Reset removes all of the flows, and their items; the flows' weights are
+// kept.
+func (f *Fair) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clear(f.flows)
+	clear(f.active)
+	f.active = f.active[:0]
+	clear(f.idle)
+	f.idle = f.idle[:0]
+	f.cur = 0
+	f.n = 0
+}
+
+// Resize resizes each flow's queue, see Queue.Resize, and sets the initial
+// capacity of new flows' queues to size. The new capacity of the queue, see
+// Cap, is returned.
+func (f *Fair) Resize(size int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initCap = max(size, 0)
+	var c int
+	for _, fl := range f.flows {
+		c += fl.q.Resize(size)
+	}
+	return c
+}
@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFair(t *testing.T) {
+	var _ Queuer = (*Fair)(nil)
+	f := NewFair(1, 4)
+	f.SetWeight("b", 2)
+	// a noisy flow doesn't delay the others
+	for i := 0; i < 6; i++ {
+		_ = f.EnqueueFlow("a", "a"+string(rune('0'+i)))
+	}
+	_ = f.Enqueue(Flowed{"b", "b0"})
+	_ = f.Enqueue(Flowed{"b", "b1"})
+	_ = f.Enqueue(Flowed{"b", "b2"})
+	_ = f.Enqueue(Flowed{"c", "c0"})
+	if err := f.Enqueue("x"); err == nil {
+		t.Error("expected an error enqueueing an item without a flow")
+	}
+	f.SetFlowFunc(func(item interface{}) string { return item.(string)[:1] })
+	_ = f.Enqueue("c1")
+	if f.Len() != 11 || f.FlowLen("a") != 6 || f.FlowLen("c") != 2 || f.FlowLen("z") != 0 {
+		t.Errorf("expected len 11, a 6, c 2, got %d, %d, %d", f.Len(), f.FlowLen("a"), f.FlowLen("c"))
+	}
+	if v, ok := f.Peek(); !ok || v != "a0" {
+		t.Errorf("expected to peek a0, got %v (%t)", v, ok)
+	}
+	// b has twice the weight of a and c
+	expected := []string{"a0", "b0", "b1", "c0", "a1", "b2", "c1", "a2", "a3", "a4", "a5"}
+	for i, v := range expected {
+		got, ok := f.Dequeue()
+		if !ok || got != v {
+			t.Errorf("%d: expected %s, got %v (%t)", i, v, got, ok)
+		}
+	}
+	if _, ok := f.Dequeue(); ok || !f.IsEmpty() {
+		t.Error("expected the queue to be empty")
+	}
+	// without an idle timeout, empty flows are removed immediately
+	if f.Flows() != 0 {
+		t.Errorf("expected no flows, got %d", f.Flows())
+	}
+	if f.Weight("b") != 2 || f.Weight("z") != 1 {
+		t.Errorf("expected weights 2 and 1, got %d and %d", f.Weight("b"), f.Weight("z"))
+	}
+	_ = f.EnqueueFlow("a", "a6")
+	f.Reset()
+	if !f.IsEmpty() || f.Len() != 0 || f.Flows() != 0 {
+		t.Errorf("expected the queue to be empty after reset, len was %d", f.Len())
+	}
+}
+
+func TestFairCost(t *testing.T) {
+	// with a quantum of 500 bytes, a flow of large items gets about the same
+	// number of bytes as a flow of small ones
+	f := NewFair(500, 0)
+	f.SetCostFunc(func(item interface{}) int { return len(item.(string)) })
+	for i := 0; i < 10; i++ {
+		_ = f.EnqueueFlow("large", strings.Repeat("l", 1000))
+		_ = f.EnqueueFlow("small", strings.Repeat("s", 100))
+	}
+	bytes := map[byte]int{}
+	for i := 0; i < 11; i++ {
+		v, _ := f.Dequeue()
+		bytes[v.(string)[0]] += len(v.(string))
+	}
+	// in two rounds, large sends nothing and then 1000 bytes, while small
+	// sends 500 bytes in each
+	if bytes['l'] != 1000 || bytes['s'] != 1000 {
+		t.Errorf("expected 1000 large and 1000 small bytes, got %d and %d", bytes['l'], bytes['s'])
+	}
+}
+
+func TestFairIdle(t *testing.T) {
+	clock := newFakeClock()
+	f := NewFair(1, 0)
+	f.SetClock(clock)
+	f.SetIdleTimeout(time.Minute)
+	_ = f.EnqueueFlow("a", 1)
+	_ = f.EnqueueFlow("b", 2)
+	f.Dequeue()
+	clock.Advance(30 * time.Second)
+	f.Dequeue()
+	if f.Flows() != 2 {
+		t.Errorf("expected 2 idle flows, got %d", f.Flows())
+	}
+	clock.Advance(30 * time.Second)
+	if f.Flows() != 1 {
+		t.Errorf("expected a to be removed, got %d flows", f.Flows())
+	}
+	// b is reused before it times out and then idles again
+	_ = f.EnqueueFlow("b", 3)
+	clock.Advance(time.Minute)
+	if f.Flows() != 1 {
+		t.Errorf("expected b to be kept while it has items, got %d flows", f.Flows())
+	}
+	f.Dequeue()
+	clock.Advance(59 * time.Second)
+	if f.Flows() != 1 {
+		t.Errorf("expected b to be idle, got %d flows", f.Flows())
+	}
+	clock.Advance(time.Second)
+	if f.Flows() != 0 {
+		t.Errorf("expected b to be removed, got %d flows", f.Flows())
+	}
+}
+
+func TestFairIdleAgain(t *testing.T) {
+	clock := newFakeClock()
+	f := NewFair(1, 0)
+	f.SetClock(clock)
+	f.SetIdleTimeout(time.Minute)
+	_ = f.EnqueueFlow("a", 1)
+	_ = f.EnqueueFlow("b", 2)
+	f.Dequeue()
+	clock.Advance(5 * time.Second)
+	f.Dequeue()
+	// a becomes empty again, after b: its first idle entry is stale
+	clock.Advance(45 * time.Second)
+	_ = f.EnqueueFlow("a", 3)
+	f.Dequeue()
+	clock.Advance(15 * time.Second)
+	if f.Flows() != 1 || f.FlowLen("a") != 0 {
+		t.Errorf("expected b to be removed and a to be kept, got %d flows", f.Flows())
+	}
+	clock.Advance(45 * time.Second)
+	if f.Flows() != 0 {
+		t.Errorf("expected a to be removed, got %d flows", f.Flows())
+	}
+}
+
+func TestFairLargeCost(t *testing.T) {
+	f := NewFair(1, 0)
+	f.SetCostFunc(func(item interface{}) int { return item.(int) })
+	tests := []struct {
+		flow  string
+		items []interface{}
+	}{
+		{"a", []interface{}{5, 1 << 40}},
+		{"b", []interface{}{3, 1<<40 + 1}},
+		{"c", []interface{}{1 << 40}},
+	}
+	for _, test := range tests {
+		for _, item := range test.items {
+			_ = f.EnqueueFlow(test.flow, item)
+		}
+	}
+	// the rounds it takes to cover a cost are computed rather than gone
+	// through one at a time: b needs fewer rounds than a, then c, which has
+	// been credited while a and b were catching up, needs the fewest, and b
+	// has kept more of its deficit than a.
+	expected := []interface{}{3, 5, 1 << 40, 1<<40 + 1, 1 << 40}
+	for i, v := range expected {
+		got, ok := f.Dequeue()
+		if !ok || got != v {
+			t.Errorf("%d: expected %v, got %v (%t)", i, v, got, ok)
+		}
+	}
+}
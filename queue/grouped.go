@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotInFlight is returned when a lease that doesn't hold its group's
+// in-flight item is acknowledged.
+var ErrNotInFlight = errors.New("lease is not in flight")
+
+// A Lease is an item dequeued from a Grouped queue, along with its group.
+// The group is locked until the lease is acknowledged with Ack; only the
+// lease of the group's in-flight item can acknowledge it, so a consumer
+// can't unlock a group that another consumer holds.
+type Lease struct {
+	Group string
+	Item  interface{}
+	id    uint64
+}
+
+// group is a Grouped queue's FIFO queue for a group key.
+type group struct {
+	key   string
+	q     *Queue
+	lease uint64 // the id of the in-flight item's lease; 0 if there is none
+}
+
+// Grouped is a thread-safe queue of groups of items, e.g. the messages of
+// each customer: items are dequeued in the order they were enqueued within
+// their group, while the groups are consumed in parallel. Once an item has
+// been dequeued, its group is locked to that consumer, and no other item of
+// the group is dequeued, until the item's lease is acknowledged with Ack.
+// Groups that are ready, i.e. have items and aren't locked, are dequeued in
+// the order they became ready.
+//
+// Groups are created when an item is first enqueued for them and removed
+// once they are empty and their in-flight item has been acknowledged.
+type Grouped struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond // signalled when a group becomes ready
+	initCap  int
+	groups   map[string]*group
+	ready    *Queue // the ready groups
+	n        int    // the number of items that haven't been dequeued
+	inFlight int
+	leases   uint64 // the id of the last lease
+	closed   bool
+}
+
+// NewGrouped returns an empty grouped queue. Each group's queue is created
+// with an initial capacity of initCap.
+func NewGrouped(initCap int) *Grouped {
+	g := &Grouped{initCap: initCap, groups: make(map[string]*group), ready: NewQueue(0)}
+	g.notEmpty = sync.NewCond(&g.mu)
+	return g
+}
+
+// Enqueue adds an item to the end of the group's queue, creating the group
+// if it doesn't exist. Once the queue is closed, ErrClosed is returned.
+func (g *Grouped) Enqueue(key string, item interface{}) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	gr, ok := g.groups[key]
+	if !ok {
+		gr = &group{key: key, q: NewQueue(g.initCap)}
+		g.groups[key] = gr
+	}
+	if err := gr.q.Enqueue(item); err != nil {
+		return err
+	}
+	g.n++
+	if gr.lease == 0 && gr.q.Len() == 1 {
+		g.setReady(gr)
+	}
+	return nil
+}
+
+// setReady adds the group to the ready groups and wakes a goroutine waiting
+// in DequeueWait, if there is one. The caller is expected to handle locking.
+func (g *Grouped) setReady(gr *group) {
+	_ = g.ready.Enqueue(gr)
+	g.notEmpty.Signal()
+}
+
+// Dequeue removes the first item of the next ready group and returns it in
+// a lease. The group is locked until the lease is acknowledged with Ack. If
+// no group is ready, a false will be returned.
+func (g *Grouped) Dequeue() (Lease, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.dequeue()
+}
+
+// dequeue is an unexported version that expects the caller to handle
+// locking.
+func (g *Grouped) dequeue() (Lease, bool) {
+	v, ok := g.ready.Dequeue()
+	if !ok {
+		return Lease{}, false
+	}
+	gr := v.(*group)
+	item, _ := gr.q.Dequeue()
+	g.leases++
+	gr.lease = g.leases
+	g.n--
+	g.inFlight++
+	if g.closed && g.n == 0 {
+		// wake the consumers waiting for the queue to drain: only one of
+		// them was woken for this item
+		g.notEmpty.Broadcast()
+	}
+	return Lease{Group: gr.key, Item: item, id: gr.lease}, true
+}
+
+// DequeueWait removes the first item of the next ready group and returns it
+// in a lease, like Dequeue. If no group is ready, it
+// blocks until one is or ctx is done, in which case the context's error is
+// returned. Once the queue is closed and all of its items have been
+// dequeued, ErrClosed is returned.
+func (g *Grouped) DequeueWait(ctx context.Context) (Lease, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ready := func() bool { return !g.ready.IsEmpty() || (g.closed && g.n == 0) }
+	if !ready() {
+		stop := context.AfterFunc(ctx, func() {
+			g.mu.Lock()
+			g.notEmpty.Broadcast()
+			g.mu.Unlock()
+		})
+		defer stop()
+		for !ready() {
+			if err := ctx.Err(); err != nil {
+				return Lease{}, err
+			}
+			g.notEmpty.Wait()
+		}
+	}
+	l, ok := g.dequeue()
+	if !ok {
+		return Lease{}, ErrClosed
+	}
+	return l, nil
+}
+
+// Ack acknowledges the lease's item, unlocking its group so that the
+// group's next item can be dequeued. If the lease doesn't hold the group's
+// in-flight item, e.g. it was already acknowledged, ErrNotInFlight is
+// returned.
+func (g *Grouped) Ack(l Lease) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	gr, ok := g.groups[l.Group]
+	if !ok || gr.lease == 0 || gr.lease != l.id {
+		return ErrNotInFlight
+	}
+	gr.lease = 0
+	g.inFlight--
+	if gr.q.IsEmpty() {
+		delete(g.groups, l.Group)
+		return nil
+	}
+	g.setReady(gr)
+	return nil
+}
+
+// Close closes the queue: no more items can be enqueued, while the items in
+// the queue can still be dequeued and acknowledged. Goroutines blocked in
+// DequeueWait return ErrClosed once all of the items have been dequeued.
+// Closing a closed queue returns ErrClosed.
+func (g *Grouped) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	g.closed = true
+	g.notEmpty.Broadcast()
+	return nil
+}
+
+// Groups returns the number of groups that have items or an in-flight item.
+func (g *Grouped) Groups() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.groups)
+}
+
+// GroupLen returns the number of items in the group's queue, not including
+// its in-flight item; 0 if there is no such group.
+func (g *Grouped) GroupLen(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	gr, ok := g.groups[key]
+	if !ok {
+		return 0
+	}
+	return gr.q.Len()
+}
+
+// InFlight returns the number of items that have been dequeued and not
+// acknowledged, which is also the number of locked groups.
+func (g *Grouped) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inFlight
+}
+
+// IsEmpty returns whether or not there are no items to dequeue; there may
+// still be in-flight items.
+func (g *Grouped) IsEmpty() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.n == 0
+}
+
+// Len returns the number of items, across all groups, that haven't been
+// dequeued.
+func (g *Grouped) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.n
+}
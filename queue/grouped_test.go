@@ -0,0 +1,212 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGrouped(t *testing.T) {
+	g := NewGrouped(2)
+	for _, test := range []struct{ key, item string }{
+		{"a", "a0"}, {"a", "a1"}, {"b", "b0"}, {"a", "a2"}, {"c", "c0"}, {"b", "b1"},
+	} {
+		if err := g.Enqueue(test.key, test.item); err != nil {
+			t.Fatalf("%s: unexpected error: %q", test.item, err)
+		}
+	}
+	if g.Len() != 6 || g.Groups() != 3 || g.GroupLen("a") != 3 || g.GroupLen("z") != 0 {
+		t.Errorf("expected len 6, 3 groups, a 3, got %d, %d, %d", g.Len(), g.Groups(), g.GroupLen("a"))
+	}
+	// each group is locked by its in-flight item
+	tests := []struct {
+		ack  string // the group acknowledged before dequeueing
+		key  string
+		item string
+		ok   bool
+	}{
+		{"", "a", "a0", true},
+		{"", "b", "b0", true},
+		{"", "c", "c0", true},
+		{"", "", "", false},
+		{"b", "b", "b1", true},
+		{"a", "a", "a1", true},
+		{"c", "", "", false},
+		{"a", "a", "a2", true},
+	}
+	leases := map[string]Lease{}
+	var a0 Lease
+	for i, test := range tests {
+		if test.ack != "" {
+			if err := g.Ack(leases[test.ack]); err != nil {
+				t.Errorf("%d: unexpected ack error: %q", i, err)
+			}
+		}
+		l, ok := g.Dequeue()
+		if l.Group != test.key || ok != test.ok || (ok && l.Item != test.item) {
+			t.Errorf("%d: expected %q %v %t, got %q %v %t", i, test.key, test.item, test.ok, l.Group, l.Item, ok)
+		}
+		if ok {
+			leases[l.Group] = l
+		}
+		if l.Item == "a0" {
+			a0 = l
+		}
+	}
+	if g.InFlight() != 2 || !g.IsEmpty() {
+		t.Errorf("expected 2 in-flight items and no items, got %d and %d", g.InFlight(), g.Len())
+	}
+	// a lease can only be acknowledged once, and only while its item is
+	// the group's in-flight item
+	if err := g.Ack(leases["c"]); err != ErrNotInFlight {
+		t.Errorf("expected ErrNotInFlight, got %v", err)
+	}
+	if err := g.Ack(a0); err != ErrNotInFlight {
+		t.Errorf("expected ErrNotInFlight for the lease of an earlier item, got %v", err)
+	}
+	if err := g.Ack(Lease{Group: "a"}); err != ErrNotInFlight {
+		t.Errorf("expected ErrNotInFlight for a lease that wasn't dequeued, got %v", err)
+	}
+	_ = g.Ack(leases["a"])
+	if err := g.Ack(leases["a"]); err != ErrNotInFlight {
+		t.Errorf("expected ErrNotInFlight for an acknowledged lease, got %v", err)
+	}
+	_ = g.Ack(leases["b"])
+	// acknowledged, empty, groups are removed
+	if g.Groups() != 0 || g.InFlight() != 0 {
+		t.Errorf("expected no groups, got %d with %d in-flight items", g.Groups(), g.InFlight())
+	}
+	_ = g.Close()
+	if err := g.Enqueue("a", "a3"); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if _, err := g.DequeueWait(context.Background()); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestGroupedDequeueWait(t *testing.T) {
+	g := NewGrouped(0)
+	_ = g.Enqueue("a", 0)
+	_ = g.Enqueue("a", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	l, err := g.DequeueWait(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	// a is locked
+	if _, err := g.DequeueWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	// acknowledging a wakes the waiting consumer
+	done := make(chan Lease)
+	go func() {
+		l, _ := g.DequeueWait(context.Background())
+		done <- l
+	}()
+	time.Sleep(time.Millisecond)
+	_ = g.Ack(l)
+	if l = <-done; l.Item != 1 {
+		t.Errorf("expected 1, got %v", l.Item)
+	}
+	// closing waits for the items to be dequeued, not acknowledged
+	_ = g.Close()
+	if _, err := g.DequeueWait(context.Background()); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := g.Ack(l); err != nil {
+		t.Errorf("unexpected error: %q", err)
+	}
+}
+
+func TestGroupedCloseWaiters(t *testing.T) {
+	g := NewGrouped(0)
+	_ = g.Enqueue("a", 0)
+	l, _ := g.Dequeue()
+	_ = g.Enqueue("a", 1)
+	// two consumers wait for a to be unlocked
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := g.DequeueWait(context.Background())
+			errs <- err
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	_ = g.Close()
+	time.Sleep(time.Millisecond)
+	// only one of them gets the last item; the other is woken once it is
+	// dequeued, without waiting for it to be acknowledged
+	_ = g.Ack(l)
+	var closed int
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == ErrClosed {
+				closed++
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the consumers")
+		}
+	}
+	if closed != 1 {
+		t.Errorf("expected 1 consumer to get ErrClosed, got %d", closed)
+	}
+}
+
+func TestGroupedWorkers(t *testing.T) {
+	const groups, items, workers = 8, 100, 4
+	g := NewGrouped(0)
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	busy := make(map[string]bool)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				l, err := g.DequeueWait(context.Background())
+				if err != nil {
+					return
+				}
+				key, item := l.Group, l.Item
+				mu.Lock()
+				if busy[key] {
+					t.Errorf("%s: consumed concurrently", key)
+				}
+				busy[key] = true
+				got[key] = append(got[key], item.(int))
+				mu.Unlock()
+				time.Sleep(time.Microsecond)
+				mu.Lock()
+				busy[key] = false
+				mu.Unlock()
+				_ = g.Ack(l)
+			}
+		}()
+	}
+	for i := 0; i < items; i++ {
+		for j := 0; j < groups; j++ {
+			_ = g.Enqueue(fmt.Sprint(j), i)
+		}
+	}
+	_ = g.Close()
+	wg.Wait()
+	for j := 0; j < groups; j++ {
+		key := fmt.Sprint(j)
+		if len(got[key]) != items {
+			t.Errorf("%s: expected %d items, got %d", key, items, len(got[key]))
+			continue
+		}
+		for i, v := range got[key] {
+			if v != i {
+				t.Errorf("%s: %d: expected %d, got %d", key, i, i, v)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package queue
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLineSize is the assumed size of a CPU cache line. Indices that are
+// written by different goroutines are padded to it so that they do not share
+// a cache line.
+const cacheLineSize = 64
+
+type cacheLinePad [cacheLineSize]byte
+
+// ceilPow2 returns the smallest power of two that is >= n; 1 if n < 1.
+func ceilPow2(n int) uint64 {
+	p := uint64(1)
+	for p < uint64(n) {
+		p <<= 1
+	}
+	return p
+}
+
+// slot is an element of an MPMC queue. seq tells the producers and the
+// consumers whose turn it is to use the slot, which orders their access to
+// item. peekers is the number of Peek calls that may be reading item; the
+// consumer that claims the slot waits for them before clearing it.
+type slot struct {
+	seq     atomic.Uint64
+	peekers atomic.Int32
+	item    interface{}
+}
+
+// MPMC is a lock-free, bounded, multi-producer/multi-consumer queue. It is
+// based on Dmitry Vyukov's bounded MPMC queue: each slot has a sequence
+// number that tells producers whether the slot is free for the current lap
+// and consumers whether it holds an item for the current lap, so that
+// claiming a slot is a single compare-and-swap on the tail, or head, index.
+//
+// The capacity is always a power of two, which allows an index to be mapped
+// to a slot with a mask instead of a modulo operation.
+//
+// Unlike Circular, there is no lock, which means that Len, IsEmpty, IsFull,
+// and Peek can only report the state of the queue at some point during the
+// call: with concurrent producers and consumers, the state may have changed
+// before the caller acts on it. Enqueue and Dequeue are lock-free; Peek is
+// not, as the consumer of the peeked item waits for Peek to finish reading
+// it.
+type MPMC struct {
+	_     cacheLinePad
+	tail  atomic.Uint64 // next position to enqueue to
+	_     cacheLinePad
+	head  atomic.Uint64 // next position to dequeue from
+	_     cacheLinePad
+	mask  uint64
+	slots []slot
+}
+
+// NewMPMC returns an empty MPMC queue whose capacity is size rounded up to
+// the next power of two. The minimum capacity is 2.
+func NewMPMC(size int) *MPMC {
+	// A slot's sequence numbers for full and free must differ, which
+	// requires at least 2 slots.
+	n := ceilPow2(size)
+	if n < 2 {
+		n = 2
+	}
+	q := &MPMC{mask: n - 1, slots: make([]slot, n)}
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// Enqueue adds an item to the queue. If the queue is full, an error is
+// returned and the item is not added.
+func (q *MPMC) Enqueue(item interface{}) error {
+	pos := q.tail.Load()
+	for {
+		s := &q.slots[pos&q.mask]
+		dif := int64(s.seq.Load() - pos)
+		switch {
+		case dif == 0:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				s.item = item
+				s.seq.Store(pos + 1)
+				return nil
+			}
+			pos = q.tail.Load()
+		case dif < 0:
+			// the slot still holds the item from the previous lap
+			return fmt.Errorf("queue full: cannot enqueue %v", item)
+		default:
+			// another producer claimed this position
+			pos = q.tail.Load()
+		}
+	}
+}
+
+// Dequeue removes the next item from the queue and returns it. If the queue
+// is empty, a false will be returned.
+func (q *MPMC) Dequeue() (interface{}, bool) {
+	pos := q.head.Load()
+	for {
+		s := &q.slots[pos&q.mask]
+		dif := int64(s.seq.Load() - (pos + 1))
+		switch {
+		case dif == 0:
+			if q.head.CompareAndSwap(pos, pos+1) {
+				// wait for any Peek that is reading the item
+				for s.peekers.Load() != 0 {
+					runtime.Gosched()
+				}
+				item := s.item
+				s.item = nil
+				// free the slot for the producer of the next lap
+				s.seq.Store(pos + q.mask + 1)
+				return item, true
+			}
+			pos = q.head.Load()
+		case dif < 0:
+			// the slot has not been filled yet
+			return nil, false
+		default:
+			// another consumer claimed this position
+			pos = q.head.Load()
+		}
+	}
+}
+
+// Peek returns the next item in the queue without removing it. If the queue
+// is empty, a false will be returned. The item may be dequeued by another
+// consumer before Peek returns.
+func (q *MPMC) Peek() (interface{}, bool) {
+	for {
+		pos := q.head.Load()
+		s := &q.slots[pos&q.mask]
+		if s.seq.Load() != pos+1 {
+			if q.head.Load() == pos {
+				return nil, false
+			}
+			continue
+		}
+		// A consumer that claimed the position before peekers was
+		// incremented has moved the head, so the item is not read; one that
+		// claims it after waits until peekers is decremented.
+		s.peekers.Add(1)
+		if q.head.Load() != pos {
+			s.peekers.Add(-1)
+			continue
+		}
+		item := s.item
+		s.peekers.Add(-1)
+		return item, true
+	}
+}
+
+// IsEmpty returns whether or not the queue is empty.
+func (q *MPMC) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// IsFull returns whether or not the queue is full.
+func (q *MPMC) IsFull() bool {
+	return q.Len() == q.Cap()
+}
+
+// Len returns the number of items in the queue. Items that have been claimed
+// by a producer, or consumer, that has not yet finished are included.
+func (q *MPMC) Len() int {
+	for {
+		head := q.head.Load()
+		tail := q.tail.Load()
+		if q.head.Load() == head {
+			n := int(tail - head)
+			if n < 0 {
+				return 0
+			}
+			if n > len(q.slots) {
+				return len(q.slots)
+			}
+			return n
+		}
+	}
+}
+
+// Cap returns the capacity of the queue.
+func (q *MPMC) Cap() int {
+	return len(q.slots)
+}
+
+// Reset removes all items from the queue. Items enqueued while the reset is
+// in progress may also be removed.
+func (q *MPMC) Reset() {
+	for {
+		if _, ok := q.Dequeue(); !ok {
+			return
+		}
+	}
+}
+
+// Resize is implemented to fulfill Queuer. The slots of a lock-free queue
+// cannot be replaced while producers and consumers may be using them, so an
+// MPMC queue cannot be resized; its capacity is returned unchanged.
+func (q *MPMC) Resize(size int) int {
+	return len(q.slots)
+}
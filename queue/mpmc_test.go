@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+var _ Queuer = (*MPMC)(nil)
+
+func TestNewMPMC(t *testing.T) {
+	tests := []struct {
+		size int
+		cap  int
+	}{
+		{0, 2}, {1, 2}, {2, 2}, {3, 4}, {8, 8}, {9, 16}, {1000, 1024},
+	}
+	for i, test := range tests {
+		q := NewMPMC(test.size)
+		if q.Cap() != test.cap {
+			t.Errorf("%d: expected cap to be %d, got %d", i, test.cap, q.Cap())
+		}
+	}
+}
+
+func TestMPMC(t *testing.T) {
+	q := NewMPMC(4)
+	if _, ok := q.Dequeue(); ok {
+		t.Error("expected dequeue on an empty queue to return false")
+	}
+	if _, ok := q.Peek(); ok {
+		t.Error("expected peek on an empty queue to return false")
+	}
+	// go around the ring a few times
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 4; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Errorf("%d: enqueue %d: unexpected error: %q", lap, i, err)
+			}
+		}
+		if !q.IsFull() || q.Len() != 4 {
+			t.Errorf("%d: expected queue to be full, len was %d", lap, q.Len())
+		}
+		err := q.Enqueue(4)
+		if err == nil || err.Error() != "queue full: cannot enqueue 4" {
+			t.Errorf("%d: expected queue full error, got %v", lap, err)
+		}
+		v, ok := q.Peek()
+		if !ok || v != 0 {
+			t.Errorf("%d: expected peek to return 0, got %v", lap, v)
+		}
+		for i := 0; i < 4; i++ {
+			v, ok := q.Dequeue()
+			if !ok || v != i {
+				t.Errorf("%d: expected %d, got %v (%t)", lap, i, v, ok)
+			}
+		}
+		if !q.IsEmpty() {
+			t.Errorf("%d: expected queue to be empty, len was %d", lap, q.Len())
+		}
+	}
+	_ = q.Enqueue(1)
+	q.Reset()
+	if !q.IsEmpty() {
+		t.Errorf("after Reset(), expected queue to be empty, len was %d", q.Len())
+	}
+	if q.Resize(16) != 4 {
+		t.Errorf("expected Resize to leave the capacity at 4, got %d", q.Cap())
+	}
+}
+
+func TestMPMCConcurrent(t *testing.T) {
+	const producers, perProducer = 4, 2500
+	q := NewMPMC(64)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				for q.Enqueue(p*perProducer+i) != nil {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+	// peeking while consumers dequeue must never see a cleared slot
+	stop := make(chan struct{})
+	peeked := make(chan error)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				peeked <- nil
+				return
+			default:
+			}
+			if v, ok := q.Peek(); ok {
+				if n, isInt := v.(int); !isInt || n < 0 || n >= producers*perProducer {
+					peeked <- fmt.Errorf("peeked invalid item %v", v)
+					return
+				}
+			}
+		}
+	}()
+	results := make(chan []int, producers)
+	for c := 0; c < producers; c++ {
+		go func() {
+			var got []int
+			for len(got) < perProducer {
+				v, ok := q.Dequeue()
+				if !ok {
+					runtime.Gosched()
+					continue
+				}
+				got = append(got, v.(int))
+			}
+			results <- got
+		}()
+	}
+	wg.Wait()
+	seen := make([]bool, producers*perProducer)
+	for c := 0; c < producers; c++ {
+		for _, v := range <-results {
+			if seen[v] {
+				t.Fatalf("item %d was dequeued twice", v)
+			}
+			seen[v] = true
+		}
+	}
+	close(stop)
+	if err := <-peeked; err != nil {
+		t.Error(err)
+	}
+	for v, ok := range seen {
+		if !ok {
+			t.Fatalf("item %d was never dequeued", v)
+		}
+	}
+}
+
+// Each benchmark has every goroutine enqueue an item and then dequeue one,
+// which keeps the queue from filling up.
+func BenchmarkMPMC(b *testing.B) {
+	q := NewMPMC(1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for q.Enqueue(1) != nil {
+			}
+			for {
+				if _, ok := q.Dequeue(); ok {
+					break
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkCircular(b *testing.B) {
+	q := NewCircular(1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for q.Enqueue(1) != nil {
+			}
+			for {
+				if _, ok := q.Dequeue(); ok {
+					break
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkChannel(b *testing.B) {
+	ch := make(chan interface{}, 1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ch <- 1
+			<-ch
+		}
+	})
+}
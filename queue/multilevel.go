@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// MaxLevels is the maximum number of levels of a MultiLevel queue.
+const MaxLevels = 64
+
+// MultiLevel is a thread-safe priority queue for a small, fixed, range of
+// priorities: 0 to levels-1. It keeps a FIFO Queue per priority level and a
+// bitmap of the levels that aren't empty, so that enqueueing and dequeueing
+// are O(1). Items with the highest priority are dequeued first; items with
+// the same priority are dequeued in the order they were enqueued.
+type MultiLevel struct {
+	mu       sync.Mutex
+	levels   []*Queue
+	nonEmpty uint64 // bit i is set if level i isn't empty
+	n        int
+}
+
+// NewMultiLevel returns a multi-level queue with the received number of
+// levels, which is capped at MaxLevels. Each level's queue is created with
+// an initial capacity of initCap.
+func NewMultiLevel(levels, initCap int) *MultiLevel {
+	levels = min(max(levels, 1), MaxLevels)
+	m := &MultiLevel{levels: make([]*Queue, levels)}
+	for i := range m.levels {
+		m.levels[i] = NewQueue(initCap)
+	}
+	return m
+}
+
+// Insert enqueues a value at the level of the received priority. If there is
+// no such level, an error is returned.
+func (m *MultiLevel) Insert(value interface{}, priority int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if priority < 0 || priority >= len(m.levels) {
+		return fmt.Errorf("priority %d out of range: must be in [0, %d)", priority, len(m.levels))
+	}
+	err := m.levels[priority].Enqueue(value)
+	if err != nil {
+		return err
+	}
+	m.nonEmpty |= 1 << uint(priority)
+	m.n++
+	return nil
+}
+
+// PopValue dequeues the first item of the highest priority level that isn't
+// empty and returns its value and priority. If the queue is empty, a false
+// will be returned.
+func (m *MultiLevel) PopValue() (interface{}, int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nonEmpty == 0 {
+		return nil, 0, false
+	}
+	l := bits.Len64(m.nonEmpty) - 1
+	v, _ := m.levels[l].Dequeue()
+	if m.levels[l].IsEmpty() {
+		m.nonEmpty &^= 1 << uint(l)
+	}
+	m.n--
+	return v, l, true
+}
+
+// PeekValue returns the value and priority of the next item to be dequeued
+// without removing it. If the queue is empty, a false will be returned.
+func (m *MultiLevel) PeekValue() (interface{}, int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nonEmpty == 0 {
+		return nil, 0, false
+	}
+	l := bits.Len64(m.nonEmpty) - 1
+	v, _ := m.levels[l].Peek()
+	return v, l, true
+}
+
+// Levels returns the number of levels.
+func (m *MultiLevel) Levels() int {
+	return len(m.levels)
+}
+
+// LevelLen returns the number of items at the level of the received
+// priority; 0 if there is no such level.
+func (m *MultiLevel) LevelLen(priority int) int {
+	if priority < 0 || priority >= len(m.levels) {
+		return 0
+	}
+	return m.levels[priority].Len()
+}
+
+// IsEmpty returns whether or not the queue is empty.
+func (m *MultiLevel) IsEmpty() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonEmpty == 0
+}
+
+// Len returns the number of items in the queue.
+func (m *MultiLevel) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.n
+}
+
+// Reset resets each level's queue; any items in the queue will be lost.
+func (m *MultiLevel) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, q := range m.levels {
+		q.Reset()
+	}
+	m.nonEmpty = 0
+	m.n = 0
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMultiLevel(t *testing.T) {
+	var _ PriorityQueuer = (*MultiLevel)(nil)
+	m := NewMultiLevel(16, 2)
+	if m.Levels() != 16 {
+		t.Errorf("expected 16 levels, got %d", m.Levels())
+	}
+	tests := []struct {
+		value    string
+		priority int
+		err      bool
+	}{
+		{"a", 3, false},
+		{"b", 15, false},
+		{"c", 3, false},
+		{"d", 0, false},
+		{"e", 16, true},
+		{"f", -1, true},
+		{"g", 15, false},
+		{"h", 3, false},
+	}
+	for i, test := range tests {
+		if err := m.Insert(test.value, test.priority); (err != nil) != test.err {
+			t.Errorf("%d: expected error to be %t, got %v", i, test.err, err)
+		}
+	}
+	if m.Len() != 6 || m.LevelLen(3) != 3 || m.LevelLen(99) != 0 {
+		t.Errorf("expected len 6 and level 3 len 3, got %d and %d", m.Len(), m.LevelLen(3))
+	}
+	if v, p, ok := m.PeekValue(); !ok || v != "b" || p != 15 {
+		t.Errorf("expected to peek b 15, got %v %d (%t)", v, p, ok)
+	}
+	expected := []struct {
+		value    string
+		priority int
+	}{
+		{"b", 15}, {"g", 15}, {"a", 3}, {"c", 3}, {"h", 3}, {"d", 0},
+	}
+	for i, test := range expected {
+		v, p, ok := m.PopValue()
+		if !ok || v != test.value || p != test.priority {
+			t.Errorf("%d: expected %s %d, got %v %d (%t)", i, test.value, test.priority, v, p, ok)
+		}
+	}
+	if _, _, ok := m.PopValue(); ok || !m.IsEmpty() {
+		t.Error("expected the queue to be empty")
+	}
+	_ = m.Insert("i", 1)
+	m.Reset()
+	if !m.IsEmpty() || m.Len() != 0 {
+		t.Errorf("expected the queue to be empty after reset, len was %d", m.Len())
+	}
+}
+
+func BenchmarkMultiLevel(b *testing.B) {
+	m := NewMultiLevel(16, 1024)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		_ = m.Insert(nil, r.Intn(16))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = m.PopValue()
+		_ = m.Insert(nil, r.Intn(16))
+	}
+}
+
+func BenchmarkMultiLevelHeapPriority(b *testing.B) {
+	pq := NewHeapPriority(1024)
+	pq.SetStable(true)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		_ = pq.Insert(nil, r.Intn(16))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = pq.PopValue()
+		_ = pq.Insert(nil, r.Intn(16))
+	}
+}
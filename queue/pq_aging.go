@@ -0,0 +1,205 @@
+package queue
+
+import (
+	"cmp"
+	"container/heap"
+	"container/list"
+	"slices"
+	"time"
+)
+
+// Aging is a policy that raises the effective priority of the items in a
+// HeapPriority the longer they wait, so that low priority items are not
+// starved by a sustained stream of higher priority ones. An item's
+// effective priority is its priority, plus By for every Every it has
+// waited, if Every > 0, plus the Boost of the last of the Steps it has
+// reached.
+type Aging struct {
+	By    int
+	Every time.Duration
+	Steps []AgingStep
+}
+
+// AgingStep is reached by an item once it has waited for After; its
+// effective priority is then raised by Boost.
+type AgingStep struct {
+	After time.Duration
+	Boost int
+}
+
+// LinearAging returns an Aging policy that raises the priority of an item
+// by by for every every that it waits; fractions of every count
+// proportionally.
+func LinearAging(by int, every time.Duration) Aging {
+	return Aging{By: by, Every: every}
+}
+
+// StepAging returns an Aging policy that raises the priority of an item by
+// the Boost of the last of the steps it has reached.
+func StepAging(steps ...AgingStep) Aging {
+	return Aging{Steps: steps}
+}
+
+// Priority returns the effective priority of an item with the received
+// priority that has waited for waited.
+func (a Aging) Priority(priority int, waited time.Duration) int {
+	var boost int
+	for _, s := range a.Steps {
+		if waited >= s.After {
+			boost = s.Boost
+		}
+	}
+	return int(a.key(priority+boost, waited))
+}
+
+// key returns priority plus the linear aging of an item that has waited for
+// waited.
+func (a Aging) key(priority int, waited time.Duration) float64 {
+	k := float64(priority)
+	if a.Every > 0 {
+		k += float64(a.By) * float64(waited) / float64(a.Every)
+	}
+	return k
+}
+
+// NewAgingHeapPriority returns a new priority queue, with the item's cap set
+// at l, if l > 0, whose items are popped highest effective priority first,
+// as defined by aging. How long an item has waited is measured using clock;
+// a nil clock is the system clock. PopValue and PeekValue return an item's
+// priority, not its effective priority.
+//
+// Linear aging raises the effective priority of all of the items at the
+// same rate, so it doesn't change their order once they are in the heap.
+// Step aging does: as the items reach a step in the order they were added,
+// the items are kept in a list, in that order, and each step keeps its place
+// in the list: the first item that has yet to reach it. The items that have
+// reached a step since are fixed in the heap lazily, when the priority queue
+// is popped or peeked at, so each item is fixed at most once per step and
+// operations stay O(log n) amortized. Items are removed from the list once
+// they have reached every step or are popped or removed.
+func NewAgingHeapPriority(l int, aging Aging, clock Clock) *HeapPriority {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	aging.Steps = slices.Clone(aging.Steps)
+	slices.SortStableFunc(aging.Steps, func(a, b AgingStep) int { return cmp.Compare(a.After, b.After) })
+	pq := NewHeapPriority(l)
+	pq.aging = &aging
+	pq.clock = clock
+	pq.base = clock.Now()
+	pq.aged = make(map[*Item]*agedItem)
+	if len(aging.Steps) > 0 {
+		pq.waiting = list.New()
+		pq.next = make([]*list.Element, len(aging.Steps))
+	}
+	pq.less = pq.agingLess
+	pq.setHeap()
+	return pq
+}
+
+// agedItem is the aging state of an item in an aging priority queue. It is
+// kept outside of Item so that priority queues that don't age their items
+// don't pay for it.
+type agedItem struct {
+	enqueued time.Time     // when the item was added
+	boost    int           // the boost of the last aging step the item reached
+	elem     *list.Element // the item's element in waiting; nil once removed
+}
+
+// agingLess orders items by decreasing effective priority. At time now,
+// the effective priority of an item with linear aging is
+//
+//	priority + boost + By*(now-enqueued)/Every
+//
+// so, when comparing two items, now cancels out and each item can be
+// compared using its effective priority at the base time, which doesn't
+// change as it waits.
+func (pq *HeapPriority) agingLess(a, b *Item) bool {
+	x, y := pq.aged[a], pq.aged[b]
+	return pq.aging.key(a.priority+x.boost, pq.base.Sub(x.enqueued)) >
+		pq.aging.key(b.priority+y.boost, pq.base.Sub(y.enqueued))
+}
+
+// enqueued records when the item was added to an aging priority queue. The
+// caller is expected to handle locking.
+func (pq *HeapPriority) enqueued(item *Item) {
+	if pq.aging == nil {
+		return
+	}
+	a := &agedItem{enqueued: pq.clock.Now()}
+	pq.aged[item] = a
+	if pq.waiting == nil {
+		return
+	}
+	a.elem = pq.waiting.PushBack(item)
+	for i, e := range pq.next {
+		if e == nil {
+			pq.next[i] = a.elem
+		}
+	}
+}
+
+// dequeued drops the aging state of an item that was popped or removed from
+// an aging priority queue. The caller is expected to handle locking.
+func (pq *HeapPriority) dequeued(item *Item) {
+	if pq.aging == nil {
+		return
+	}
+	a, ok := pq.aged[item]
+	if !ok {
+		return
+	}
+	delete(pq.aged, item)
+	if a.elem == nil {
+		return
+	}
+	for i, e := range pq.next {
+		if e == a.elem {
+			pq.next[i] = e.Next()
+		}
+	}
+	pq.waiting.Remove(a.elem)
+}
+
+// age raises the boost of the items that have reached an aging step since
+// it was last called and fixes their position in the heap. The caller is
+// expected to handle locking.
+func (pq *HeapPriority) age() {
+	if pq.waiting == nil {
+		return
+	}
+	now := pq.clock.Now()
+	for i, s := range pq.aging.Steps {
+		e := pq.next[i]
+		for ; e != nil; e = e.Next() {
+			item := e.Value.(*Item)
+			a := pq.aged[item]
+			if now.Sub(a.enqueued) < s.After {
+				break
+			}
+			a.boost = s.Boost
+			heap.Fix(pq.h, item.index)
+		}
+		pq.next[i] = e
+	}
+	// the steps are sorted, so the items before the last step's place have
+	// reached every step.
+	last := pq.next[len(pq.next)-1]
+	for e := pq.waiting.Front(); e != nil && e != last; e = pq.waiting.Front() {
+		pq.aged[e.Value.(*Item)].elem = nil
+		pq.waiting.Remove(e)
+	}
+}
+
+// resetAging drops the aging state of all of the items. The caller is
+// expected to handle locking.
+func (pq *HeapPriority) resetAging() {
+	if pq.aging == nil {
+		return
+	}
+	clear(pq.aged)
+	if pq.waiting != nil {
+		pq.waiting.Init()
+		clear(pq.next)
+	}
+}
@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgingPriority(t *testing.T) {
+	tests := []struct {
+		aging    Aging
+		priority int
+		waited   time.Duration
+		expected int
+	}{
+		{Aging{}, 3, time.Hour, 3},
+		{LinearAging(1, time.Second), 3, 0, 3},
+		{LinearAging(1, time.Second), 3, 2500 * time.Millisecond, 5},
+		{LinearAging(2, time.Second), -3, 3 * time.Second, 3},
+		{StepAging(AgingStep{time.Second, 5}, AgingStep{time.Minute, 50}), 1, 0, 1},
+		{StepAging(AgingStep{time.Second, 5}, AgingStep{time.Minute, 50}), 1, time.Second, 6},
+		{StepAging(AgingStep{time.Second, 5}, AgingStep{time.Minute, 50}), 1, time.Hour, 51},
+		{Aging{By: 1, Every: time.Second, Steps: []AgingStep{{time.Second, 10}}}, 1, 2 * time.Second, 13},
+	}
+	for i, test := range tests {
+		if p := test.aging.Priority(test.priority, test.waited); p != test.expected {
+			t.Errorf("%d: expected %d, got %d", i, test.expected, p)
+		}
+	}
+}
+
+func TestAgingHeapPriorityLinear(t *testing.T) {
+	clock := newFakeClock()
+	// a priority 0 item catches up with a priority 10 one after 10s
+	pq := NewAgingHeapPriority(0, LinearAging(1, time.Second), clock)
+	pq.SetStable(true)
+	pq.PushValue("low", 0)
+	clock.Advance(5 * time.Second)
+	pq.PushValue("high", 10)
+	clock.Advance(4 * time.Second)
+	pq.PushValue("mid", 6)
+	// effective priorities: low 9, high 14, mid 6
+	if v, p, _ := pq.PeekValue(); v != "high" || p != 10 {
+		t.Errorf("peek: expected high 10, got %v %d", v, p)
+	}
+	// a sustained stream of high priority items doesn't starve low
+	var got []interface{}
+	for i := 0; i < 4; i++ {
+		clock.Advance(time.Second)
+		pq.PushValue(i, 10)
+		v, _ := pq.Dequeue()
+		got = append(got, v)
+	}
+	// at 11s low's effective priority is 11, equal to that of the item added
+	// at 10s, and it was added first
+	expected := []interface{}{"high", "low", 0, 1}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("%d: expected %v, got %v", i, v, got[i])
+		}
+	}
+}
+
+func TestAgingHeapPriorityStep(t *testing.T) {
+	clock := newFakeClock()
+	pq := NewAgingHeapPriority(0, StepAging(AgingStep{time.Minute, 100}, AgingStep{time.Second, 10}), clock)
+	a := pq.PushValue("a", 1)
+	pq.PushValue("b", 2)
+	clock.Advance(time.Second)
+	pq.PushValue("c", 5)
+	pq.PushValue("d", 20)
+	pq.PushValue("e", 15)
+	// a and b have reached the 1s step: a 11, b 12, c 5, d 20, e 15
+	var got []interface{}
+	for _, f := range []func(){
+		func() {},
+		func() { pq.Remove(a) },
+		func() { clock.Advance(time.Second) }, // c reaches the 1s step: 15
+		func() { pq.PushValue("f", 11) },
+		func() {
+			clock.Advance(time.Minute) // f reaches both steps: 111
+			pq.PushValue("g", 50)
+		},
+		func() {},
+	} {
+		f()
+		v, _ := pq.Dequeue()
+		got = append(got, v)
+	}
+	expected := []interface{}{"d", "e", "c", "b", "f", "g"}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("%d: expected %v, got %v", i, v, got[i])
+		}
+	}
+	if !pq.IsEmpty() {
+		t.Errorf("expected the priority queue to be empty, got %d items", pq.Len())
+	}
+	// popped items don't linger in the steps' lists
+	if pq.waiting.Len() != 0 || len(pq.aged) != 0 {
+		t.Errorf("expected no waiting items after popping, got %d, %d", pq.waiting.Len(), len(pq.aged))
+	}
+	// reset items don't linger in the steps' lists
+	pq.PushValue("g", 1)
+	pq.Reset()
+	if pq.waiting.Len() != 0 || len(pq.aged) != 0 {
+		t.Errorf("expected no waiting items, got %d, %d", pq.waiting.Len(), len(pq.aged))
+	}
+	pq.PushValue("h", 1)
+	if v, p, ok := pq.PopValue(); v != "h" || p != 1 || !ok {
+		t.Errorf("pop: expected h 1 true, got %v %d %t", v, p, ok)
+	}
+}
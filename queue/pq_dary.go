@@ -0,0 +1,165 @@
+package queue
+
+import "sync"
+
+// DAryHeap is a thread-safe priority queue implemented as a d-ary heap:
+// each node has up to d children instead of 2. A larger d makes the heap
+// shallower, which makes pushes and priority changes cheaper and pops more
+// expensive; it also improves the locality of the items' slice.
+type DAryHeap struct {
+	mu    sync.Mutex
+	d     int
+	items []*Item
+	less  func(a, b *Item) bool
+}
+
+// NewDAryHeap returns a new d-ary heap, with the item's cap set at l, if
+// l > 0, whose items are ordered by less; see NewHeapPriorityFunc. A d < 2 is
+// set to 2. A nil less is MaxPriority.
+func NewDAryHeap(d, l int, less func(a, b *Item) bool) *DAryHeap {
+	if less == nil {
+		less = MaxPriority
+	}
+	return &DAryHeap{d: max(d, 2), items: make([]*Item, 0, max(l, 0)), less: less}
+}
+
+// Insert pushes a value, with the received priority, onto the heap. It never
+// returns an error.
+func (h *DAryHeap) Insert(value interface{}, priority int) error {
+	h.PushValue(value, priority)
+	return nil
+}
+
+// PushValue pushes a value, with the received priority, onto the heap. The
+// returned Handle can be used to Update or Remove the item.
+func (h *DAryHeap) PushValue(value interface{}, priority int) *Handle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	item := &Item{value: value, priority: priority, index: len(h.items)}
+	h.items = append(h.items, item)
+	h.up(item.index)
+	return item
+}
+
+// PopValue removes the first item, per the heap's ordering, and returns its
+// value and priority. If the heap is empty, a false will be returned.
+func (h *DAryHeap) PopValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	item := h.remove(0)
+	return item.value, item.priority, true
+}
+
+// PeekValue returns the value and priority of the first item without
+// removing it. If the heap is empty, a false will be returned.
+func (h *DAryHeap) PeekValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	return h.items[0].value, h.items[0].priority, true
+}
+
+// Update changes the priority of the item referred to by handle. If the item
+// is no longer in the heap, a false will be returned.
+func (h *DAryHeap) Update(handle *Handle, priority int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !PQueue(h.items).contains(handle) {
+		return false
+	}
+	handle.priority = priority
+	if !h.up(handle.index) {
+		h.down(handle.index)
+	}
+	return true
+}
+
+// Remove removes the item referred to by handle and returns its value. If
+// the item is no longer in the heap, a false will be returned.
+func (h *DAryHeap) Remove(handle *Handle) (interface{}, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !PQueue(h.items).contains(handle) {
+		return nil, false
+	}
+	return h.remove(handle.index).value, true
+}
+
+// Len returns the number of items in the heap.
+func (h *DAryHeap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.items)
+}
+
+// Reset removes all of the items from the heap.
+func (h *DAryHeap) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, item := range h.items {
+		item.index = -1
+	}
+	clear(h.items)
+	h.items = h.items[:0]
+}
+
+// remove removes the item at i, replacing it with the last item.
+func (h *DAryHeap) remove(i int) *Item {
+	item := h.items[i]
+	n := len(h.items) - 1
+	h.swap(i, n)
+	h.items[n] = nil
+	h.items = h.items[:n]
+	if i < n && !h.up(i) {
+		h.down(i)
+	}
+	item.index = -1
+	return item
+}
+
+func (h *DAryHeap) swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+// up moves the item at i up the heap and returns whether or not it moved.
+func (h *DAryHeap) up(i int) bool {
+	j := i
+	for j > 0 {
+		p := (j - 1) / h.d
+		if !h.less(h.items[j], h.items[p]) {
+			break
+		}
+		h.swap(j, p)
+		j = p
+	}
+	return j != i
+}
+
+// down moves the item at i down the heap.
+func (h *DAryHeap) down(i int) {
+	for {
+		first := h.d*i + 1
+		if first >= len(h.items) {
+			return
+		}
+		// m is the child that comes first
+		m := first
+		for c := first + 1; c < first+h.d && c < len(h.items); c++ {
+			if h.less(h.items[c], h.items[m]) {
+				m = c
+			}
+		}
+		if !h.less(h.items[m], h.items[i]) {
+			return
+		}
+		h.swap(i, m)
+		i = m
+	}
+}
@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestDAryHeap(t *testing.T) {
+	tests := []struct {
+		d        int
+		less     func(a, b *Item) bool
+		pushed   []int
+		expected []int
+	}{
+		{2, nil, []int{3, 1, 4, 1, 5, 9, 2, 6}, []int{9, 6, 5, 4, 3, 2, 1, 1}},
+		{4, MinPriority, []int{3, 1, 4, 1, 5, 9, 2, 6}, []int{1, 1, 2, 3, 4, 5, 6, 9}},
+		{1, MinPriority, []int{8, 7, 6}, []int{6, 7, 8}},
+		{8, nil, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+	}
+	for i, test := range tests {
+		h := NewDAryHeap(test.d, 0, test.less)
+		for _, p := range test.pushed {
+			_ = h.Insert(p, p)
+		}
+		if v, p, ok := h.PeekValue(); !ok || p != test.expected[0] || v != p {
+			t.Errorf("%d: expected to peek %d, got %v %d (%t)", i, test.expected[0], v, p, ok)
+		}
+		for j, expected := range test.expected {
+			v, p, ok := h.PopValue()
+			if !ok || p != expected || v != p {
+				t.Errorf("%d: item %d: expected %d, got %v %d (%t)", i, j, expected, v, p, ok)
+			}
+		}
+		if _, _, ok := h.PopValue(); ok {
+			t.Errorf("%d: expected the heap to be empty", i)
+		}
+	}
+}
+
+func TestDAryHeapHandles(t *testing.T) {
+	h := NewDAryHeap(3, 4, MinPriority)
+	handles := make([]*Handle, 10)
+	for i := range handles {
+		handles[i] = h.PushValue(i, i)
+	}
+	h.Update(handles[9], -1)
+	h.Update(handles[0], 20)
+	if v, ok := h.Remove(handles[5]); !ok || v != 5 {
+		t.Errorf("expected to remove 5, got %v (%t)", v, ok)
+	}
+	if _, ok := h.Remove(handles[5]); ok {
+		t.Error("expected 5 to not be found")
+	}
+	var got []interface{}
+	for v, _, ok := h.PopValue(); ok; v, _, ok = h.PopValue() {
+		got = append(got, v)
+	}
+	expected := []interface{}{9, 1, 2, 3, 4, 6, 7, 8, 0}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("%d: expected %v, got %v", i, v, got[i])
+		}
+	}
+	if h.Update(handles[1], 3) {
+		t.Error("expected a popped item to not be found")
+	}
+}
@@ -13,8 +13,16 @@ package queue
 // license that can be found in the LICENSE file.
 
 import (
+	"cmp"
 	"container/heap"
+	"container/list"
+	"fmt"
+	"io"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/mohae/firkin/codec"
 )
 
 // An Item is something we manage in a priority queue.
@@ -22,13 +30,78 @@ type Item struct {
 	value    interface{} // The value of the item; arbitrary.
 	priority int         // The priority of the item in the queue.
 	// The index is needed by update and is maintained by the heap.Interface methods.
-	index int // The index of the item in the heap.
+	index int    // The index of the item in the heap.
+	seq   uint64 // The order in which the item was added; breaks ties when stable.
+}
+
+// NewItem returns an item with the received value and priority, for use
+// with HeapPriority.Init.
+func NewItem(value interface{}, priority int) *Item {
+	return &Item{value: value, priority: priority, index: -1}
 }
 
-// A HeapPriority implements heap.Interface and holds Items.
+// Value returns the item's value.
+func (i *Item) Value() interface{} { return i.value }
+
+// Priority returns the item's priority. It is meant to be used by less funcs,
+// which are called with the priority queue locked; otherwise the priority
+// may be concurrently updated.
+func (i *Item) Priority() int { return i.priority }
+
+// MaxPriority is a less func that orders items by decreasing priority; it is
+// the default ordering of a HeapPriority.
+func MaxPriority(a, b *Item) bool { return a.priority > b.priority }
+
+// MinPriority is a less func that orders items by increasing priority.
+func MinPriority(a, b *Item) bool { return a.priority < b.priority }
+
+// A Handle refers to an item in a HeapPriority. It is returned by PushValue
+// and can be used to update the item's priority or to remove it.
+type Handle = Item
+
+// PriorityQueuer is the interface shared by the priority queues, which
+// differ in how they are implemented, and so in their performance, and in
+// what else they support.
+//
+// They also differ in the order in which they pop items: HeapPriority,
+// DAryHeap, and PairingHeap pop the highest priority first unless they are
+// created with a less func, e.g. MinPriority, and MultiLevel always does,
+// while RadixHeap and Calendar always pop the lowest priority first. When
+// swapping one implementation for another, create it with the same order.
+type PriorityQueuer interface {
+	Insert(value interface{}, priority int) error
+	PopValue() (value interface{}, priority int, ok bool)
+	PeekValue() (value interface{}, priority int, ok bool)
+	Len() int
+	Reset()
+}
+
+// A HeapPriority is a thread-safe, heap based, priority queue. Items with the
+// highest priority are popped first. It also implements heap.Interface.
 type HeapPriority struct {
-	mu    *sync.Mutex
-	items PQueue
+	mu       *sync.Mutex
+	items    PQueue
+	h        heap.Interface // orders items
+	less     func(a, b *Item) bool
+	stable   bool
+	seq      uint64 // the seq of the next item
+	initCap  int
+	size     int // the maximum number of items; 0 if unbounded
+	priority func(item interface{}) int
+	codec    codec.Codec
+	aging    *Aging              // nil unless the priority queue ages its items
+	clock    Clock               // measures how long items have waited
+	base     time.Time           // the time linear aging is relative to
+	aged     map[*Item]*agedItem // the aging state of the items
+	waiting  *list.List          // the items yet to reach every aging step, in order
+	next     []*list.Element     // per aging step, the first item yet to reach it
+}
+
+// Prioritized is an item with a priority. Enqueueing a Prioritized item
+// onto a HeapPriority adds its Value with its Priority.
+type Prioritized struct {
+	Value    interface{}
+	Priority int
 }
 
 // PQueue represents a priority queue
@@ -59,62 +132,473 @@ func (pq *PQueue) Pop() interface{} {
 	old := *pq
 	n := len(old)
 	item := old[n-1]
+	old[n-1] = nil
 	item.index = -1 // for safety
 	*pq = old[0 : n-1]
 	return item
 }
 
+// contains returns whether or not the item is in the priority queue.
+func (pq PQueue) contains(item *Item) bool {
+	return item != nil && item.index >= 0 && item.index < len(pq) && pq[item.index] == item
+}
+
 // update modifies the priority and vlaue of an Item in the queue.
-func (pq *PQueue) update(item *Item, value string, priority int) {
+func (pq *PQueue) update(item *Item, value interface{}, priority int) {
 	item.value = value
 	item.priority = priority
 	heap.Fix(pq, item.index)
 }
 
+// lessHeap orders a PQueue using a less func instead of PQueue.Less.
+type lessHeap struct {
+	*PQueue
+	less func(a, b *Item) bool
+}
+
+func (h lessHeap) Less(i, j int) bool {
+	return h.less((*h.PQueue)[i], (*h.PQueue)[j])
+}
+
+// stableHeap orders a PQueue using a less func; items that are equal are
+// ordered by when they were added.
+type stableHeap lessHeap
+
+func (h stableHeap) Less(i, j int) bool {
+	a, b := (*h.PQueue)[i], (*h.PQueue)[j]
+	if h.less(a, b) {
+		return true
+	}
+	if h.less(b, a) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
 // NewHeapPriority returns a new priority queue with the item's cap set at l; if l > 0.
 func NewHeapPriority(l int) *HeapPriority {
-	if l <= 0 {
-		return &HeapPriority{mu: &sync.Mutex{}}
+	return NewHeapPriorityFunc(l, nil)
+}
+
+// NewHeapPriorityFunc returns a new priority queue, with the item's cap set
+// at l, if l > 0, whose items are ordered by less: an item for which less
+// returns true is popped before the other. This allows for min-heaps,
+// priorities other than the int priority, e.g. floats or times held by the
+// values, and ordering by multiple keys. A nil less is MaxPriority.
+func NewHeapPriorityFunc(l int, less func(a, b *Item) bool) *HeapPriority {
+	pq := &HeapPriority{mu: &sync.Mutex{}}
+	if l > 0 {
+		pq.items = make([]*Item, 0, l)
+		pq.initCap = l
+	}
+	pq.less = less
+	pq.setHeap()
+	return pq
+}
+
+// setHeap sets the heap.Interface used to order the items. The caller is
+// expected to handle locking.
+func (pq *HeapPriority) setHeap() {
+	switch {
+	case pq.stable:
+		less := pq.less
+		if less == nil {
+			less = MaxPriority
+		}
+		pq.h = stableHeap{PQueue: &pq.items, less: less}
+	case pq.less != nil:
+		pq.h = lessHeap{PQueue: &pq.items, less: pq.less}
+	default:
+		pq.h = &pq.items
 	}
-	return &HeapPriority{mu: &sync.Mutex{}, items: make([]*Item, l, l)}
 }
 
-func (pq HeapPriority) Len() int {
+// SetStable sets whether or not the priority queue is stable. Items that are
+// equal, e.g. have the same priority, are popped in the order they were
+// added when the priority queue is stable; otherwise their order is
+// unspecified. By default, a priority queue is not stable.
+func (pq *HeapPriority) SetStable(stable bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if pq.stable == stable {
+		return
+	}
+	pq.stable = stable
+	pq.setHeap()
+	heap.Init(pq.h)
+}
+
+// push assigns the item's seq and pushes it onto the heap. The caller is
+// expected to handle locking.
+func (pq *HeapPriority) push(item *Item) {
+	item.seq = pq.seq
+	pq.seq++
+	pq.enqueued(item)
+	heap.Push(pq.h, item)
+}
+
+// NewBoundedHeapPriority returns a new priority queue that holds at most size
+// items. Once it is full, items cannot be added until one is removed.
+func NewBoundedHeapPriority(size int) *HeapPriority {
+	pq := NewHeapPriority(size)
+	pq.size = size
+	return pq
+}
+
+// Len returns the number of items in the priority queue.
+func (pq *HeapPriority) Len() int {
 	pq.mu.Lock()
 	defer pq.mu.Unlock()
 	return pq.items.Len()
 }
 
-func (pq HeapPriority) Less(i, j int) bool {
+func (pq *HeapPriority) Less(i, j int) bool {
 	pq.mu.Lock()
 	defer pq.mu.Unlock()
 	// We want Pop to give us the highest, not lowest, priority so we use greater than here.
-	return pq.items.Less(i, j)
+	return pq.h.Less(i, j)
 }
 
-func (pq HeapPriority) Swap(i, j int) {
+func (pq *HeapPriority) Swap(i, j int) {
 	pq.mu.Lock()
 	pq.items.Swap(i, j)
 	pq.mu.Unlock()
 }
 
-// Push pushes an item onto the priority queue.
+// Push pushes an item onto the priority queue. Along with Len, Less, Swap,
+// and Pop, it implements heap.Interface; use PushValue instead, which keeps
+// the heap invariants.
 func (pq *HeapPriority) Push(x interface{}) {
 	pq.mu.Lock()
+	x.(*Item).seq = pq.seq
+	pq.seq++
+	pq.enqueued(x.(*Item))
 	pq.items.Push(x)
 	pq.mu.Unlock()
 }
 
-// Pop pops the next item from the priority queue.
+// Pop pops the last item from the priority queue's slice. It is part of
+// heap.Interface; use PopValue instead to get the highest priority item.
 func (pq *HeapPriority) Pop() interface{} {
 	pq.mu.Lock()
 	defer pq.mu.Unlock()
-	return pq.items.Pop()
+	item := pq.items.Pop()
+	pq.dequeued(item.(*Item))
+	return item
 }
 
 // update modifies the priority and value of an Item in the queue.
-func (pq *HeapPriority) update(item *Item, value string, priority int) {
+func (pq *HeapPriority) update(item *Item, value interface{}, priority int) {
+	pq.mu.Lock()
+	item.value = value
+	item.priority = priority
+	heap.Fix(pq.h, item.index)
+	pq.mu.Unlock()
+}
+
+// Insert pushes a value, with the received priority, onto the priority queue.
+// If a bounded priority queue is full, an error is returned.
+func (pq *HeapPriority) Insert(value interface{}, priority int) error {
+	if pq.PushValue(value, priority) == nil {
+		return fmt.Errorf("queue full: cannot enqueue %v", value)
+	}
+	return nil
+}
+
+// PushValue pushes a value, with the received priority, onto the priority
+// queue. The returned Handle can be used to Update or Remove the item. If a
+// bounded priority queue is full, the value is not pushed and nil is
+// returned.
+func (pq *HeapPriority) PushValue(value interface{}, priority int) *Handle {
+	item := &Item{value: value, priority: priority}
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if pq.isFull() {
+		return nil
+	}
+	pq.push(item)
+	return item
+}
+
+// PopValue removes the highest priority item from the priority queue and
+// returns its value and priority. If the priority queue is empty, a false
+// will be returned.
+func (pq *HeapPriority) PopValue() (interface{}, int, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.items) == 0 {
+		return nil, 0, false
+	}
+	pq.age()
+	item := heap.Pop(pq.h).(*Item)
+	pq.dequeued(item)
+	return item.value, item.priority, true
+}
+
+// PeekValue returns the value and priority of the highest priority item
+// without removing it from the priority queue. If the priority queue is
+// empty, a false will be returned.
+func (pq *HeapPriority) PeekValue() (interface{}, int, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.items) == 0 {
+		return nil, 0, false
+	}
+	pq.age()
+	return pq.items[0].value, pq.items[0].priority, true
+}
+
+// Update changes the priority of the item referred to by h. If the item is
+// no longer in the priority queue, a false will be returned.
+func (pq *HeapPriority) Update(h *Handle, priority int) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if !pq.items.contains(h) {
+		return false
+	}
+	h.priority = priority
+	heap.Fix(pq.h, h.index)
+	return true
+}
+
+// Remove removes the item referred to by h from the priority queue and
+// returns its value. If the item is no longer in the priority queue, a false
+// will be returned.
+func (pq *HeapPriority) Remove(h *Handle) (interface{}, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if !pq.items.contains(h) {
+		return nil, false
+	}
+	item := heap.Remove(pq.h, h.index).(*Item)
+	pq.dequeued(item)
+	return item.value, true
+}
+
+// Init replaces the contents of the priority queue with the received items,
+// which are created with NewItem, and establishes the heap invariants in
+// O(n). The items can be used as handles; handles to the items that were
+// in the priority queue are no longer valid. If there are more items than a
+// bounded priority queue can hold, an error is returned and the priority
+// queue is left unchanged.
+func (pq *HeapPriority) Init(items []*Item) error {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if pq.size > 0 && len(items) > pq.size {
+		return fmt.Errorf("cannot init a priority queue with a capacity of %d with %d items", pq.size, len(items))
+	}
+	pq.reset()
+	pq.items = append(pq.items, items...)
+	for i, item := range pq.items {
+		item.index = i
+		item.seq = pq.seq
+		pq.seq++
+		pq.enqueued(item)
+	}
+	heap.Init(pq.h)
+	return nil
+}
+
+// Reset removes all of the items from the priority queue. Handles to the
+// removed items are no longer valid.
+func (pq *HeapPriority) Reset() {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	pq.reset()
+}
+
+// reset is an unexported version that expects the caller to handle locking.
+func (pq *HeapPriority) reset() {
+	for _, item := range pq.items {
+		item.index = -1
+	}
+	clear(pq.items)
+	pq.items = pq.items[:0]
+	pq.resetAging()
+}
+
+// SetPriorityFunc sets the function that Enqueue uses to get the priority of
+// items that are not Prioritized.
+func (pq *HeapPriority) SetPriorityFunc(f func(item interface{}) int) {
+	pq.mu.Lock()
+	pq.priority = f
+	pq.mu.Unlock()
+}
+
+// Enqueue adds an item to the priority queue. The priority of a Prioritized
+// item is its Priority; the priority of any other item is provided by the
+// priority func, see SetPriorityFunc. If there is no priority func, or if a
+// bounded priority queue is full, an error is returned.
+func (pq *HeapPriority) Enqueue(item interface{}) error {
 	pq.mu.Lock()
-	pq.items.update(item, value, priority)
+	defer pq.mu.Unlock()
+	var i *Item
+	if p, ok := item.(Prioritized); ok {
+		i = &Item{value: p.Value, priority: p.Priority}
+	} else if pq.priority != nil {
+		i = &Item{value: item, priority: pq.priority(item)}
+	} else {
+		return fmt.Errorf("cannot enqueue %v: no priority", item)
+	}
+	if pq.isFull() {
+		return fmt.Errorf("queue full: cannot enqueue %v", item)
+	}
+	pq.push(i)
+	return nil
+}
+
+// Dequeue removes the highest priority item from the priority queue and
+// returns its value. If the priority queue is empty, a false will be
+// returned.
+func (pq *HeapPriority) Dequeue() (interface{}, bool) {
+	v, _, ok := pq.PopValue()
+	return v, ok
+}
+
+// Peek returns the value of the highest priority item without removing it
+// from the priority queue. If the priority queue is empty, a false will be
+// returned.
+func (pq *HeapPriority) Peek() (interface{}, bool) {
+	v, _, ok := pq.PeekValue()
+	return v, ok
+}
+
+// IsEmpty returns whether or not the priority queue is empty.
+func (pq *HeapPriority) IsEmpty() bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return len(pq.items) == 0
+}
+
+// IsFull returns whether or not the priority queue is full. An unbounded
+// priority queue is never full.
+func (pq *HeapPriority) IsFull() bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.isFull()
+}
+
+// isFull is an unexported version that expects the caller to handle locking.
+func (pq *HeapPriority) isFull() bool {
+	return pq.size > 0 && len(pq.items) >= pq.size
+}
+
+// Cap returns the capacity of the priority queue: the maximum number of items
+// for a bounded priority queue, otherwise the current capacity of its slice.
+func (pq *HeapPriority) Cap() int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if pq.size > 0 {
+		return pq.size
+	}
+	return cap(pq.items)
+}
+
+// Resize resizes the priority queue and returns its new capacity. Like
+// Queue.Resize, the capacity will not be less than the number of items in the
+// priority queue or its initial capacity; for a bounded priority queue, the
+// new capacity is also the new bound.
+func (pq *HeapPriority) Resize(size int) int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	size = max(size, len(pq.items), pq.initCap)
+	tmp := make(PQueue, len(pq.items), size)
+	copy(tmp, pq.items)
+	pq.items = tmp
+	if pq.size > 0 {
+		pq.size = size
+	}
+	return size
+}
+
+// SetCodec sets the codec used by Snapshot and Restore to encode and decode
+// the values of the items. By default, codec.Gob is used.
+func (pq *HeapPriority) SetCodec(c codec.Codec) {
+	pq.mu.Lock()
+	pq.codec = c
+	pq.mu.Unlock()
+}
+
+// itemCodec returns the priority queue's codec. The caller is expected to
+// handle locking.
+func (pq *HeapPriority) itemCodec() codec.Codec {
+	if pq.codec == nil {
+		return codec.Gob{}
+	}
+	return pq.codec
+}
+
+// Snapshot writes the values and priorities of the items, in heap order, or
+// in the order they were added if the priority queue is stable, along with
+// the capacity of the priority queue, to w. The items are copied under the
+// lock and encoded after it is released.
+func (pq *HeapPriority) Snapshot(w io.Writer) error {
+	pq.mu.Lock()
+	s := &codec.Snapshot{
+		Kind:       "heap",
+		Cap:        cap(pq.items),
+		InitCap:    pq.initCap,
+		Bounded:    pq.size > 0,
+		Items:      make([]interface{}, 0, len(pq.items)),
+		Priorities: make([]int, 0, len(pq.items)),
+	}
+	if pq.size > 0 {
+		s.Cap = pq.size
+	}
+	items := pq.items
+	if pq.stable {
+		items = slices.Clone(items)
+		slices.SortFunc(items, func(a, b *Item) int { return cmp.Compare(a.seq, b.seq) })
+	}
+	for _, item := range items {
+		s.Items = append(s.Items, item.value)
+		s.Priorities = append(s.Priorities, item.priority)
+	}
+	c := pq.itemCodec()
+	pq.mu.Unlock()
+	return codec.Encode(w, c, s)
+}
+
+// Restore replaces the items of the priority queue with those of a snapshot
+// written by Snapshot. Any items in the priority queue will be lost. The
+// items of an aging priority queue are restored as if they had just been
+// added: how long they had waited isn't part of the snapshot.
+func (pq *HeapPriority) Restore(r io.Reader) error {
+	pq.mu.Lock()
+	c := pq.itemCodec()
+	pq.mu.Unlock()
+	s, err := codec.Decode(r, c)
+	if err != nil {
+		return err
+	}
+	if s.Kind != "heap" {
+		return fmt.Errorf("cannot restore a %s snapshot to a priority queue", s.Kind)
+	}
+	if len(s.Priorities) != len(s.Items) {
+		return fmt.Errorf("cannot restore a priority queue snapshot without priorities")
+	}
+	if len(s.Items) > s.Cap {
+		return fmt.Errorf("cannot restore %d items to a priority queue with a capacity of %d", len(s.Items), s.Cap)
+	}
+	items := make(PQueue, len(s.Items), s.Cap)
+	for i, v := range s.Items {
+		items[i] = &Item{value: v, priority: s.Priorities[i], index: i, seq: uint64(i)}
+	}
+	pq.mu.Lock()
+	pq.reset()
+	pq.items = items
+	pq.seq = uint64(len(items))
+	for _, item := range items {
+		pq.enqueued(item)
+	}
+	// the items are in heap order, or insertion order if the snapshot is of
+	// a stable priority queue.
+	heap.Init(pq.h)
+	pq.initCap = s.InitCap
+	pq.size = 0
+	if s.Bounded {
+		pq.size = s.Cap
+	}
 	pq.mu.Unlock()
+	return nil
 }
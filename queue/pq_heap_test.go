@@ -12,8 +12,13 @@
 package queue
 
 import (
+	"bytes"
 	"container/heap"
+	"fmt"
+	"math/rand"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestPQHeap(t *testing.T) {
@@ -27,11 +32,11 @@ func TestPQHeap(t *testing.T) {
 	pq := NewHeapPriority(len(items))
 	i := 0
 	for value, priority := range items {
-		pq.items[i] = &Item{
+		pq.items = append(pq.items, &Item{
 			value:    value,
 			priority: priority,
 			index:    i,
-		}
+		})
 		i++
 	}
 	heap.Init(&pq.items)
@@ -63,3 +68,335 @@ func TestPQHeap(t *testing.T) {
 		i++
 	}
 }
+
+func TestPQHeapSnapshotRestore(t *testing.T) {
+	pq := NewHeapPriority(0)
+	for i, v := range []string{"a", "b", "c", "d"} {
+		heap.Push(&pq.items, &Item{value: v, priority: i})
+	}
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r := NewHeapPriority(0)
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	for _, v := range []string{"d", "c", "b", "a"} {
+		item := heap.Pop(&r.items).(*Item)
+		if item.value != v {
+			t.Errorf("expected %s, got %v", v, item.value)
+		}
+	}
+}
+
+func TestPQHeapValues(t *testing.T) {
+	pq := NewHeapPriority(2)
+	if err := pq.Init([]*Item{NewItem("a", 1), NewItem("b", 4), NewItem("c", 3)}); err != nil {
+		t.Fatalf("init: unexpected error: %q", err)
+	}
+	d := pq.PushValue("d", 2)
+	e := pq.PushValue("e", 0)
+	if v, p, ok := pq.PeekValue(); !ok || v != "b" || p != 4 {
+		t.Errorf("peek: expected b 4, got %v %d (%t)", v, p, ok)
+	}
+	if !pq.Update(d, 5) {
+		t.Error("update: expected true, got false")
+	}
+	if v, ok := pq.Remove(e); !ok || v != "e" {
+		t.Errorf("remove: expected e, got %v (%t)", v, ok)
+	}
+	if _, ok := pq.Remove(e); ok {
+		t.Error("remove: expected a removed item to not be found")
+	}
+	if pq.Update(e, 1) {
+		t.Error("update: expected a removed item to not be found")
+	}
+	expected := []struct {
+		value    string
+		priority int
+	}{
+		{"d", 5}, {"b", 4}, {"c", 3}, {"a", 1},
+	}
+	for i, test := range expected {
+		v, p, ok := pq.PopValue()
+		if !ok || v != test.value || p != test.priority {
+			t.Errorf("%d: expected %s %d, got %v %d (%t)", i, test.value, test.priority, v, p, ok)
+		}
+	}
+	if _, _, ok := pq.PopValue(); ok {
+		t.Error("expected the priority queue to be empty")
+	}
+	f := pq.PushValue("f", 1)
+	pq.Reset()
+	if pq.Len() != 0 || pq.Update(f, 2) {
+		t.Errorf("expected an empty priority queue after reset, len was %d", pq.Len())
+	}
+}
+
+func TestPQHeapQueuer(t *testing.T) {
+	var _ Queuer = (*HeapPriority)(nil)
+	pq := NewBoundedHeapPriority(3)
+	if err := pq.Enqueue("a"); err == nil {
+		t.Error("expected an error enqueueing an item without a priority, got nil")
+	}
+	pq.SetPriorityFunc(func(item interface{}) int { return len(item.(string)) })
+	tests := []struct {
+		item interface{}
+		err  bool
+		full bool
+	}{
+		{"aa", false, false},
+		{Prioritized{"b", 3}, false, false},
+		{"ccccc", false, true},
+		{"dddd", true, true},
+	}
+	for i, test := range tests {
+		err := pq.Enqueue(test.item)
+		if (err != nil) != test.err {
+			t.Errorf("%d: expected error to be %t, got %v", i, test.err, err)
+		}
+		if pq.IsFull() != test.full {
+			t.Errorf("%d: expected IsFull to be %t, got %t", i, test.full, pq.IsFull())
+		}
+	}
+	if pq.PushValue("e", 1) != nil {
+		t.Error("expected PushValue on a full priority queue to return nil")
+	}
+	if n := pq.Resize(4); n != 4 || pq.Cap() != 4 || pq.IsFull() {
+		t.Errorf("expected cap to be 4 after resize, got %d, %d", n, pq.Cap())
+	}
+	if n := pq.Resize(1); n != 3 {
+		t.Errorf("expected resize to not go below the number of items, got %d", n)
+	}
+	items := []*Item{NewItem("f", 1), NewItem("g", 2), NewItem("h", 3), NewItem("i", 4)}
+	if err := pq.Init(items); err == nil {
+		t.Error("expected an error initializing a bounded priority queue with too many items, got nil")
+	}
+	v, ok := pq.Peek()
+	if !ok || v != "ccccc" {
+		t.Errorf("peek: expected ccccc, got %v (%t)", v, ok)
+	}
+	for i, expected := range []interface{}{"ccccc", "b", "aa"} {
+		v, ok := pq.Dequeue()
+		if !ok || v != expected {
+			t.Errorf("%d: expected %v, got %v (%t)", i, expected, v, ok)
+		}
+	}
+	if !pq.IsEmpty() {
+		t.Errorf("expected the priority queue to be empty, len was %d", pq.Len())
+	}
+	if NewHeapPriority(2).IsFull() {
+		t.Error("expected an unbounded priority queue to never be full")
+	}
+}
+
+func TestPQHeapFunc(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+		deadline time.Time
+	}
+	now := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		less     func(a, b *Item) bool
+		values   []interface{}
+		priority []int
+		expected []interface{}
+	}{
+		{MinPriority, []interface{}{"a", "b", "c"}, []int{2, 1, 3}, []interface{}{"b", "a", "c"}},
+		{nil, []interface{}{"a", "b", "c"}, []int{2, 1, 3}, []interface{}{"c", "a", "b"}},
+		{
+			func(a, b *Item) bool { return a.Value().(float64) < b.Value().(float64) },
+			[]interface{}{1.5, -0.25, 1.25}, []int{0, 0, 0}, []interface{}{-0.25, 1.25, 1.5},
+		},
+		{
+			func(a, b *Item) bool { return a.Value().(time.Time).Before(b.Value().(time.Time)) },
+			[]interface{}{now.Add(time.Minute), now, now.Add(time.Second)}, []int{0, 0, 0},
+			[]interface{}{now, now.Add(time.Second), now.Add(time.Minute)},
+		},
+		{
+			// priority, then deadline
+			func(a, b *Item) bool {
+				x, y := a.Value().(job), b.Value().(job)
+				if x.priority != y.priority {
+					return x.priority > y.priority
+				}
+				return x.deadline.Before(y.deadline)
+			},
+			[]interface{}{job{"a", 1, now.Add(time.Hour)}, job{"b", 2, now.Add(time.Hour)}, job{"c", 1, now}},
+			[]int{0, 0, 0},
+			[]interface{}{job{"b", 2, now.Add(time.Hour)}, job{"c", 1, now}, job{"a", 1, now.Add(time.Hour)}},
+		},
+	}
+	for i, test := range tests {
+		pq := NewHeapPriorityFunc(0, test.less)
+		for j, v := range test.values {
+			pq.PushValue(v, test.priority[j])
+		}
+		for j, expected := range test.expected {
+			v, ok := pq.Dequeue()
+			if !ok || v != expected {
+				t.Errorf("%d: item %d: expected %v, got %v (%t)", i, j, expected, v, ok)
+			}
+		}
+	}
+	// updating a priority reorders the item using less
+	pq := NewHeapPriorityFunc(0, MinPriority)
+	a := pq.PushValue("a", 1)
+	pq.PushValue("b", 2)
+	pq.Update(a, 3)
+	if v, _ := pq.Peek(); v != "b" {
+		t.Errorf("expected b, got %v", v)
+	}
+}
+
+func TestPQHeapStable(t *testing.T) {
+	pq := NewHeapPriority(2)
+	pq.SetStable(true)
+	for i := 0; i < 20; i++ {
+		pq.PushValue(i, i%2)
+	}
+	// the reallocation doesn't affect the order
+	pq.Resize(64)
+	var got []interface{}
+	for i := 0; i < 5; i++ {
+		v, _ := pq.Dequeue()
+		got = append(got, v)
+	}
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r := NewHeapPriority(0)
+	r.SetStable(true)
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	_ = r.Enqueue(Prioritized{20, 1})
+	for v, ok := r.Dequeue(); ok; v, ok = r.Dequeue() {
+		got = append(got, v)
+	}
+	expected := []interface{}{1, 3, 5, 7, 9, 11, 13, 15, 17, 19, 20, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("%d: expected %v, got %v", i, v, got[i])
+		}
+	}
+	// with a less func, equal items are also popped in the order they were
+	// added
+	pq = NewHeapPriorityFunc(0, MinPriority)
+	pq.SetStable(true)
+	h := pq.PushValue("a", 2)
+	pq.PushValue("b", 1)
+	pq.PushValue("c", 1)
+	pq.Update(h, 1)
+	for i, expected := range []string{"a", "b", "c"} {
+		if v, _ := pq.Dequeue(); v != expected {
+			t.Errorf("%d: expected %s, got %v", i, expected, v)
+		}
+	}
+}
+
+// priorityQueuers are constructors for each priority queue, all ordered
+// lowest priority first.
+var priorityQueuers = []struct {
+	name string
+	new  func() PriorityQueuer
+}{
+	{"HeapPriority", func() PriorityQueuer { return NewHeapPriorityFunc(0, MinPriority) }},
+	{"DAryHeap2", func() PriorityQueuer { return NewDAryHeap(2, 0, MinPriority) }},
+	{"DAryHeap4", func() PriorityQueuer { return NewDAryHeap(4, 0, MinPriority) }},
+	{"DAryHeap8", func() PriorityQueuer { return NewDAryHeap(8, 0, MinPriority) }},
+	{"PairingHeap", func() PriorityQueuer { return NewPairingHeap(MinPriority) }},
+	{"RadixHeap", func() PriorityQueuer { return NewRadixHeap() }},
+	{"Calendar", func() PriorityQueuer { return NewCalendar() }},
+}
+
+func TestPriorityQueuerOrder(t *testing.T) {
+	// the default order of each priority queue: highest, or lowest,
+	// priority first
+	tests := []struct {
+		name    string
+		pq      PriorityQueuer
+		highest bool
+	}{
+		{"HeapPriority", NewHeapPriority(0), true},
+		{"DAryHeap", NewDAryHeap(4, 0, nil), true},
+		{"PairingHeap", NewPairingHeap(nil), true},
+		{"MultiLevel", NewMultiLevel(4, 0), true},
+		{"RadixHeap", NewRadixHeap(), false},
+		{"Calendar", NewCalendar(), false},
+	}
+	for _, test := range tests {
+		for _, p := range []int{1, 3, 2} {
+			_ = test.pq.Insert(p, p)
+		}
+		expected := 1
+		if test.highest {
+			expected = 3
+		}
+		if _, p, ok := test.pq.PopValue(); !ok || p != expected {
+			t.Errorf("%s: expected %d, got %d (%t)", test.name, expected, p, ok)
+		}
+	}
+}
+
+func TestPriorityQueuers(t *testing.T) {
+	for _, test := range priorityQueuers {
+		pq := test.new()
+		r := rand.New(rand.NewSource(1))
+		var expected []int
+		last := 0
+		for i := 0; i < 5000; i++ {
+			if len(expected) == 0 || r.Intn(5) < 3 {
+				// the priorities are monotone, for the radix heap
+				p := last + r.Intn(1000)
+				if err := pq.Insert(p, p); err != nil {
+					t.Fatalf("%s: %d: unexpected error: %q", test.name, i, err)
+				}
+				expected = append(expected, p)
+				continue
+			}
+			slices.Sort(expected)
+			v, p, ok := pq.PopValue()
+			if !ok || p != expected[0] || v != p {
+				t.Fatalf("%s: %d: expected %d, got %v %d (%t)", test.name, i, expected[0], v, p, ok)
+			}
+			last = p
+			expected = expected[1:]
+		}
+		if pq.Len() != len(expected) {
+			t.Errorf("%s: expected len %d, got %d", test.name, len(expected), pq.Len())
+		}
+		pq.Reset()
+		if _, _, ok := pq.PeekValue(); ok {
+			t.Errorf("%s: expected the priority queue to be empty after reset", test.name)
+		}
+	}
+}
+
+// BenchmarkPriorityQueuers runs each priority queue through a hold model
+// workload, as in event simulation: after filling the queue, each iteration
+// pops the first item and pushes one with a later priority.
+func BenchmarkPriorityQueuers(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		for _, test := range priorityQueuers {
+			b.Run(fmt.Sprintf("%s/%d", test.name, size), func(b *testing.B) {
+				pq := test.new()
+				r := rand.New(rand.NewSource(1))
+				for i := 0; i < size; i++ {
+					_ = pq.Insert(nil, r.Intn(size))
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, p, _ := pq.PopValue()
+					_ = pq.Insert(nil, p+r.Intn(size))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// An ItemOf is something we manage in a HeapPriorityOf.
+type ItemOf[T any] struct {
+	value    T   // The value of the item.
+	priority int // The priority of the item in the queue.
+	index    int // The index of the item in the heap.
+}
+
+// PQueueOf represents a priority queue of T. It implements heap.Interface.
+type PQueueOf[T any] []*ItemOf[T]
+
+func (pq PQueueOf[T]) Len() int { return len(pq) }
+
+func (pq PQueueOf[T]) Less(i, j int) bool {
+	return pq[i].priority > pq[j].priority
+}
+
+func (pq PQueueOf[T]) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+// Push pushes an item onto the priority queue.
+func (pq *PQueueOf[T]) Push(x any) {
+	item := x.(*ItemOf[T])
+	item.index = len(*pq)
+	*pq = append(*pq, item)
+}
+
+// Pop pops the next item from the priority queue.
+func (pq *PQueueOf[T]) Pop() any {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1 // for safety
+	*pq = old[0 : n-1]
+	return item
+}
+
+// A HeapPriorityOf is a thread-safe, heap based, priority queue of T. Items
+// with the highest priority are popped first. Unlike HeapPriority, the heap
+// invariants are maintained by HeapPriorityOf; callers do not use
+// container/heap.
+type HeapPriorityOf[T any] struct {
+	mu    sync.Mutex
+	items PQueueOf[T]
+}
+
+// NewHeapPriorityOf returns a new priority queue with the item's cap set at
+// l; if l > 0.
+func NewHeapPriorityOf[T any](l int) *HeapPriorityOf[T] {
+	if l <= 0 {
+		return &HeapPriorityOf[T]{}
+	}
+	return &HeapPriorityOf[T]{items: make(PQueueOf[T], 0, l)}
+}
+
+// Len returns the number of items in the priority queue.
+func (pq *HeapPriorityOf[T]) Len() int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.items.Len()
+}
+
+// Push pushes a value, with the received priority, onto the priority queue.
+func (pq *HeapPriorityOf[T]) Push(value T, priority int) {
+	pq.mu.Lock()
+	heap.Push(&pq.items, &ItemOf[T]{value: value, priority: priority})
+	pq.mu.Unlock()
+}
+
+// Pop removes the highest priority item from the priority queue and returns
+// its value and priority. If the priority queue is empty, a false will be
+// returned.
+func (pq *HeapPriorityOf[T]) Pop() (T, int, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.items) == 0 {
+		var zero T
+		return zero, 0, false
+	}
+	item := heap.Pop(&pq.items).(*ItemOf[T])
+	return item.value, item.priority, true
+}
+
+// Peek returns the value and priority of the highest priority item without
+// removing it from the priority queue. If the priority queue is empty, a
+// false will be returned.
+func (pq *HeapPriorityOf[T]) Peek() (T, int, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.items) == 0 {
+		var zero T
+		return zero, 0, false
+	}
+	return pq.items[0].value, pq.items[0].priority, true
+}
@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestHeapPriorityOf(t *testing.T) {
+	pq := NewHeapPriorityOf[string](4)
+	if _, _, ok := pq.Pop(); ok {
+		t.Error("expected Pop on an empty priority queue to return false")
+	}
+	items := map[string]int{
+		"banana": 3, "apple": 2, "pear": 4, "grapefruit": 5,
+	}
+	for value, priority := range items {
+		pq.Push(value, priority)
+	}
+	v, p, ok := pq.Peek()
+	if !ok || v != "grapefruit" || p != 5 {
+		t.Errorf("expected peek to return grapefruit 5 true, got %s %d %t", v, p, ok)
+	}
+	expected := []struct {
+		priority int
+		value    string
+	}{
+		{5, "grapefruit"},
+		{4, "pear"},
+		{3, "banana"},
+		{2, "apple"},
+	}
+	for i := 0; pq.Len() > 0; i++ {
+		v, p, _ := pq.Pop()
+		if p != expected[i].priority || v != expected[i].value {
+			t.Errorf("%d: expected %v got %s %d", i, expected[i], v, p)
+		}
+	}
+}
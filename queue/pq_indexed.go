@@ -0,0 +1,125 @@
+package queue
+
+import "sync"
+
+// keyed is the value of an item in an IndexedPriority's heap.
+type keyed[K comparable] struct {
+	key   K
+	value interface{}
+}
+
+// IndexedPriority is a thread-safe priority queue whose items are identified
+// by a comparable key. An item's priority can be changed, or the item
+// removed, knowing only its key, e.g. for decrease-key in Dijkstra's
+// algorithm. Contains and Priority are O(1); the other operations are
+// O(log n).
+type IndexedPriority[K comparable] struct {
+	mu    sync.Mutex
+	pq    *HeapPriority
+	items map[K]*Handle
+}
+
+// NewIndexedPriority returns a new indexed priority queue with the item's
+// cap set at l; if l > 0. Items are ordered by their priorities using less:
+// if less(a, b) is true, the item with priority a is popped first. A nil less
+// pops the highest priority first.
+func NewIndexedPriority[K comparable](l int, less func(a, b int) bool) *IndexedPriority[K] {
+	f := MaxPriority
+	if less != nil {
+		f = func(a, b *Item) bool { return less(a.priority, b.priority) }
+	}
+	return &IndexedPriority[K]{pq: NewHeapPriorityFunc(l, f), items: make(map[K]*Handle, max(l, 0))}
+}
+
+// Upsert adds the value, with the received priority, using key. If there is
+// already an item with that key, its value and priority are replaced
+// instead. Whether or not the item was added is returned.
+func (ip *IndexedPriority[K]) Upsert(key K, value interface{}, priority int) bool {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	if h, ok := ip.items[key]; ok {
+		ip.pq.update(h, keyed[K]{key: key, value: value}, priority)
+		return false
+	}
+	ip.items[key] = ip.pq.PushValue(keyed[K]{key: key, value: value}, priority)
+	return true
+}
+
+// Priority returns the priority of the item with key. If there is no such
+// item, a false will be returned.
+func (ip *IndexedPriority[K]) Priority(key K) (int, bool) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	h, ok := ip.items[key]
+	if !ok {
+		return 0, false
+	}
+	return h.priority, true
+}
+
+// Contains returns whether or not there is an item with key.
+func (ip *IndexedPriority[K]) Contains(key K) bool {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	_, ok := ip.items[key]
+	return ok
+}
+
+// Remove removes the item with key and returns its value. If there is no such
+// item, a false will be returned.
+func (ip *IndexedPriority[K]) Remove(key K) (interface{}, bool) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	h, ok := ip.items[key]
+	if !ok {
+		return nil, false
+	}
+	delete(ip.items, key)
+	v, _ := ip.pq.Remove(h)
+	return v.(keyed[K]).value, true
+}
+
+// Pop removes the first item, per the priority queue's ordering, and returns
+// its key, value, and priority. If the priority queue is empty, a false will
+// be returned.
+func (ip *IndexedPriority[K]) Pop() (K, interface{}, int, bool) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	v, p, ok := ip.pq.PopValue()
+	if !ok {
+		var zero K
+		return zero, nil, 0, false
+	}
+	k := v.(keyed[K])
+	delete(ip.items, k.key)
+	return k.key, k.value, p, true
+}
+
+// Peek returns the key, value, and priority of the first item without
+// removing it. If the priority queue is empty, a false will be returned.
+func (ip *IndexedPriority[K]) Peek() (K, interface{}, int, bool) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	v, p, ok := ip.pq.PeekValue()
+	if !ok {
+		var zero K
+		return zero, nil, 0, false
+	}
+	k := v.(keyed[K])
+	return k.key, k.value, p, true
+}
+
+// Len returns the number of items in the priority queue.
+func (ip *IndexedPriority[K]) Len() int {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	return len(ip.items)
+}
+
+// Reset removes all of the items from the priority queue.
+func (ip *IndexedPriority[K]) Reset() {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	ip.pq.Reset()
+	clear(ip.items)
+}
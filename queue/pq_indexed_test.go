@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestIndexedPriority(t *testing.T) {
+	ip := NewIndexedPriority[string](0, nil)
+	tests := []struct {
+		key      string
+		value    interface{}
+		priority int
+		added    bool
+	}{
+		{"a", 1, 3, true},
+		{"b", 2, 5, true},
+		{"c", 3, 1, true},
+		{"a", 4, 6, false},
+		{"c", 5, 0, false},
+	}
+	for i, test := range tests {
+		if added := ip.Upsert(test.key, test.value, test.priority); added != test.added {
+			t.Errorf("%d: expected added to be %t, got %t", i, test.added, added)
+		}
+	}
+	if ip.Len() != 3 {
+		t.Errorf("expected len 3, got %d", ip.Len())
+	}
+	if p, ok := ip.Priority("a"); !ok || p != 6 {
+		t.Errorf("expected a to have priority 6, got %d (%t)", p, ok)
+	}
+	if _, ok := ip.Priority("d"); ok {
+		t.Error("expected d to not be found")
+	}
+	if v, ok := ip.Remove("b"); !ok || v != 2 {
+		t.Errorf("expected to remove 2, got %v (%t)", v, ok)
+	}
+	if ip.Contains("b") {
+		t.Error("expected b to have been removed")
+	}
+	if _, ok := ip.Remove("b"); ok {
+		t.Error("expected b to not be found")
+	}
+	for i, expected := range []struct {
+		key      string
+		value    interface{}
+		priority int
+	}{
+		{"a", 4, 6}, {"c", 5, 0},
+	} {
+		k, v, p, ok := ip.Pop()
+		if !ok || k != expected.key || v != expected.value || p != expected.priority {
+			t.Errorf("%d: expected %v, got %s %v %d (%t)", i, expected, k, v, p, ok)
+		}
+	}
+	if _, _, _, ok := ip.Pop(); ok || ip.Contains("a") {
+		t.Error("expected the priority queue to be empty")
+	}
+}
+
+func TestIndexedPriorityDijkstra(t *testing.T) {
+	type edge struct {
+		to     int
+		weight int
+	}
+	graph := map[int][]edge{
+		0: {{1, 4}, {2, 1}},
+		2: {{1, 2}, {3, 5}},
+		1: {{3, 1}},
+	}
+	dist := map[int]int{0: 0}
+	ip := NewIndexedPriority[int](0, func(a, b int) bool { return a < b })
+	ip.Upsert(0, nil, 0)
+	for ip.Len() > 0 {
+		n, _, d, _ := ip.Pop()
+		for _, e := range graph[n] {
+			if nd, ok := dist[e.to]; ok && nd <= d+e.weight {
+				continue
+			}
+			dist[e.to] = d + e.weight
+			ip.Upsert(e.to, nil, d+e.weight)
+		}
+	}
+	for n, expected := range []int{0, 3, 1, 4} {
+		if dist[n] != expected {
+			t.Errorf("%d: expected distance %d, got %d", n, expected, dist[n])
+		}
+	}
+}
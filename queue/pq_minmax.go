@@ -0,0 +1,208 @@
+package queue
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// MinMaxHeap is a thread-safe double-ended priority queue, implemented as a
+// min-max heap: the levels of the heap alternate between min levels, whose
+// items have a priority less than or equal to that of their descendants, and
+// max levels, whose items have a priority greater than or equal to that of
+// their descendants. Both the lowest and the highest priority items can be
+// found in O(1) and removed in O(log n).
+type MinMaxHeap struct {
+	mu    sync.Mutex
+	items []*Item
+}
+
+// NewMinMaxHeap returns a new min-max heap with the item's cap set at l; if
+// l > 0.
+func NewMinMaxHeap(l int) *MinMaxHeap {
+	if l <= 0 {
+		return &MinMaxHeap{}
+	}
+	return &MinMaxHeap{items: make([]*Item, 0, l)}
+}
+
+// Len returns the number of items in the heap.
+func (h *MinMaxHeap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.items)
+}
+
+// Push pushes a value, with the received priority, onto the heap.
+func (h *MinMaxHeap) Push(value interface{}, priority int) {
+	h.mu.Lock()
+	h.items = append(h.items, &Item{value: value, priority: priority, index: len(h.items)})
+	h.bubbleUp(len(h.items) - 1)
+	h.mu.Unlock()
+}
+
+// Pop removes the highest priority item and returns its value and priority;
+// like HeapPriority, the highest priority is popped first. If the heap is
+// empty, a false will be returned.
+func (h *MinMaxHeap) Pop() (interface{}, int, bool) {
+	return h.PopMax()
+}
+
+// PopMax removes the highest priority item and returns its value and
+// priority. If the heap is empty, a false will be returned.
+func (h *MinMaxHeap) PopMax() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	return h.remove(h.max())
+}
+
+// PopMin removes the lowest priority item and returns its value and
+// priority. If the heap is empty, a false will be returned.
+func (h *MinMaxHeap) PopMin() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	return h.remove(0)
+}
+
+// PeekMax returns the value and priority of the highest priority item
+// without removing it. If the heap is empty, a false will be returned.
+func (h *MinMaxHeap) PeekMax() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	item := h.items[h.max()]
+	return item.value, item.priority, true
+}
+
+// PeekMin returns the value and priority of the lowest priority item
+// without removing it. If the heap is empty, a false will be returned.
+func (h *MinMaxHeap) PeekMin() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) == 0 {
+		return nil, 0, false
+	}
+	return h.items[0].value, h.items[0].priority, true
+}
+
+// Reset removes all of the items from the heap.
+func (h *MinMaxHeap) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.items)
+	h.items = h.items[:0]
+}
+
+// max returns the index of the highest priority item: one of the root's
+// children, which are on the first max level, or the root if it has none.
+// The heap must not be empty.
+func (h *MinMaxHeap) max() int {
+	switch len(h.items) {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	if h.items[2].priority > h.items[1].priority {
+		return 2
+	}
+	return 1
+}
+
+// remove removes the item at i, replacing it with the last item.
+func (h *MinMaxHeap) remove(i int) (interface{}, int, bool) {
+	item := h.items[i]
+	n := len(h.items) - 1
+	h.swap(i, n)
+	h.items[n] = nil
+	h.items = h.items[:n]
+	if i < n {
+		h.trickleDown(i)
+	}
+	item.index = -1
+	return item.value, item.priority, true
+}
+
+func (h *MinMaxHeap) swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+// isMinLevel returns whether or not i is on a min level; the root is on
+// level 0.
+func isMinLevel(i int) bool {
+	return bits.Len(uint(i+1))%2 == 1
+}
+
+// before returns whether or not the item at i belongs above the item at j:
+// on a min level, an item with a lower priority; on a max level, an item
+// with a higher priority.
+func (h *MinMaxHeap) before(i, j int, onMin bool) bool {
+	if onMin {
+		return h.items[i].priority < h.items[j].priority
+	}
+	return h.items[i].priority > h.items[j].priority
+}
+
+func (h *MinMaxHeap) bubbleUp(i int) {
+	if i == 0 {
+		return
+	}
+	onMin := isMinLevel(i)
+	p := (i - 1) / 2
+	// if the item belongs on the parent's level, which is of the other kind,
+	// move it there and continue from there.
+	if h.before(i, p, !onMin) {
+		h.swap(i, p)
+		h.bubbleUpLevels(p, !onMin)
+		return
+	}
+	h.bubbleUpLevels(i, onMin)
+}
+
+// bubbleUpLevels moves the item at i up through its grandparents, which are
+// on levels of the same kind.
+func (h *MinMaxHeap) bubbleUpLevels(i int, onMin bool) {
+	for i > 2 {
+		g := ((i-1)/2 - 1) / 2
+		if !h.before(i, g, onMin) {
+			return
+		}
+		h.swap(i, g)
+		i = g
+	}
+}
+
+func (h *MinMaxHeap) trickleDown(i int) {
+	onMin := isMinLevel(i)
+	for {
+		// m is the child or grandchild that belongs highest
+		m := -1
+		for _, c := range [...]int{2*i + 1, 2*i + 2, 4*i + 3, 4*i + 4, 4*i + 5, 4*i + 6} {
+			if c < len(h.items) && (m < 0 || h.before(c, m, onMin)) {
+				m = c
+			}
+		}
+		if m < 0 || !h.before(m, i, onMin) {
+			return
+		}
+		h.swap(m, i)
+		if m <= 2*i+2 {
+			// m is a child: its children were also candidates, so the
+			// heap is in order.
+			return
+		}
+		if p := (m - 1) / 2; h.before(p, m, onMin) {
+			h.swap(m, p)
+		}
+		i = m
+	}
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestMinMaxHeap(t *testing.T) {
+	h := NewMinMaxHeap(0)
+	if _, _, ok := h.PeekMin(); ok {
+		t.Error("expected an empty heap")
+	}
+	tests := []struct {
+		push             []int
+		expectedMin      int
+		expectedMax      int
+		popMin           bool
+		expectedPriority int
+	}{
+		{[]int{5}, 5, 5, false, 5},
+		{[]int{3, 8}, 3, 8, true, 3},
+		{[]int{1, 9, 4}, 1, 9, false, 9},
+		{[]int{7, 2, 6}, 1, 8, true, 1},
+		{nil, 2, 8, false, 8},
+		{nil, 2, 7, true, 2},
+	}
+	for i, test := range tests {
+		for _, p := range test.push {
+			h.Push(p*10, p)
+		}
+		if v, p, ok := h.PeekMin(); !ok || p != test.expectedMin || v != p*10 {
+			t.Errorf("%d: expected min %d, got %v %d (%t)", i, test.expectedMin, v, p, ok)
+		}
+		if v, p, ok := h.PeekMax(); !ok || p != test.expectedMax || v != p*10 {
+			t.Errorf("%d: expected max %d, got %v %d (%t)", i, test.expectedMax, v, p, ok)
+		}
+		pop := h.Pop
+		if test.popMin {
+			pop = h.PopMin
+		}
+		if _, p, ok := pop(); !ok || p != test.expectedPriority {
+			t.Errorf("%d: expected to pop %d, got %d (%t)", i, test.expectedPriority, p, ok)
+		}
+	}
+}
+
+func TestMinMaxHeapRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	h := NewMinMaxHeap(8)
+	var expected []int
+	for i := 0; i < 2000; i++ {
+		if len(expected) == 0 || r.Intn(3) > 0 {
+			p := r.Intn(100)
+			h.Push(nil, p)
+			expected = append(expected, p)
+			continue
+		}
+		slices.Sort(expected)
+		var p int
+		if r.Intn(2) == 0 {
+			_, p, _ = h.PopMin()
+			if p != expected[0] {
+				t.Fatalf("%d: expected min %d, got %d", i, expected[0], p)
+			}
+			expected = expected[1:]
+		} else {
+			_, p, _ = h.PopMax()
+			if p != expected[len(expected)-1] {
+				t.Fatalf("%d: expected max %d, got %d", i, expected[len(expected)-1], p)
+			}
+			expected = expected[:len(expected)-1]
+		}
+	}
+	if h.Len() != len(expected) {
+		t.Errorf("expected len %d, got %d", len(expected), h.Len())
+	}
+	h.Reset()
+	if _, _, ok := h.PopMax(); ok {
+		t.Error("expected an empty heap after reset")
+	}
+}
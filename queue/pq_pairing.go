@@ -0,0 +1,193 @@
+package queue
+
+import "sync"
+
+// PairingNode is an item in a PairingHeap. It is returned by PushValue and
+// can be used to change the item's priority with DecreaseKey.
+type PairingNode struct {
+	item    Item
+	child   *PairingNode // the first child
+	next    *PairingNode // the next sibling
+	prev    *PairingNode // the previous sibling or, for a first child, the parent
+	removed bool
+}
+
+// PairingHeap is a thread-safe priority queue implemented as a pairing heap:
+// a heap-ordered tree whose root is the first item. Pushing, melding, and
+// DecreaseKey are O(1); popping is O(log n) amortized.
+type PairingHeap struct {
+	mu   sync.Mutex
+	root *PairingNode
+	n    int
+	less func(a, b *Item) bool
+}
+
+// NewPairingHeap returns a new pairing heap whose items are ordered by less;
+// see NewHeapPriorityFunc. A nil less is MaxPriority.
+func NewPairingHeap(less func(a, b *Item) bool) *PairingHeap {
+	if less == nil {
+		less = MaxPriority
+	}
+	return &PairingHeap{less: less}
+}
+
+// before returns whether or not a comes before b.
+func (h *PairingHeap) before(a, b *PairingNode) bool {
+	return h.less(&a.item, &b.item)
+}
+
+// Insert pushes a value, with the received priority, onto the heap. It never
+// returns an error.
+func (h *PairingHeap) Insert(value interface{}, priority int) error {
+	h.PushValue(value, priority)
+	return nil
+}
+
+// PushValue pushes a value, with the received priority, onto the heap. The
+// returned node can be used with DecreaseKey.
+func (h *PairingHeap) PushValue(value interface{}, priority int) *PairingNode {
+	n := &PairingNode{item: Item{value: value, priority: priority}}
+	h.mu.Lock()
+	h.root = h.link(h.root, n)
+	h.n++
+	h.mu.Unlock()
+	return n
+}
+
+// PopValue removes the first item, per the heap's ordering, and returns its
+// value and priority. If the heap is empty, a false will be returned.
+func (h *PairingHeap) PopValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.root == nil {
+		return nil, 0, false
+	}
+	n := h.root
+	h.root = h.mergePairs(n.child)
+	if h.root != nil {
+		h.root.prev = nil
+	}
+	h.n--
+	n.child, n.removed = nil, true
+	return n.item.value, n.item.priority, true
+}
+
+// PeekValue returns the value and priority of the first item without
+// removing it. If the heap is empty, a false will be returned.
+func (h *PairingHeap) PeekValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.root == nil {
+		return nil, 0, false
+	}
+	return h.root.item.value, h.root.item.priority, true
+}
+
+// DecreaseKey changes the priority of n to one that comes before, or ties
+// with, its current priority, per the heap's ordering; for a min-heap, that
+// is a lower priority. The node must have been pushed onto, or melded into,
+// this heap. If n has been popped, or the priority would move n back in the
+// heap, the priority is not changed and a false will be returned.
+func (h *PairingHeap) DecreaseKey(n *PairingNode, priority int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n.removed || h.less(&n.item, &Item{value: n.item.value, priority: priority}) {
+		return false
+	}
+	n.item.priority = priority
+	if n == h.root {
+		return true
+	}
+	// cut n, and its subtree, from its parent and link it with the root
+	if n.prev.child == n {
+		n.prev.child = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	}
+	n.prev, n.next = nil, nil
+	h.root = h.link(h.root, n)
+	return true
+}
+
+// Meld moves all of the items of other into the heap in O(1); other is left
+// empty. Both heaps must use the same ordering. To avoid deadlocks, two
+// heaps must not be melded into each other concurrently.
+func (h *PairingHeap) Meld(other *PairingHeap) {
+	if other == h {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	h.root = h.link(h.root, other.root)
+	h.n += other.n
+	other.root, other.n = nil, 0
+}
+
+// Len returns the number of items in the heap.
+func (h *PairingHeap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.n
+}
+
+// Reset removes all of the items from the heap. Nodes that were in the heap
+// must not be used with DecreaseKey afterwards.
+func (h *PairingHeap) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.root, h.n = nil, 0
+}
+
+// link links two trees and returns the root of the resulting tree: the root
+// that comes second becomes the first child of the other.
+func (h *PairingHeap) link(a, b *PairingNode) *PairingNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if h.before(b, a) {
+		a, b = b, a
+	}
+	b.prev = a
+	b.next = a.child
+	if a.child != nil {
+		a.child.prev = b
+	}
+	a.child = b
+	return a
+}
+
+// mergePairs merges a list of siblings into one tree, using the standard
+// two passes: link the siblings in pairs, left to right, then link the
+// resulting trees right to left.
+func (h *PairingHeap) mergePairs(first *PairingNode) *PairingNode {
+	var pairs *PairingNode // the linked pairs, in reverse order
+	for first != nil {
+		a, b := first, first.next
+		if b == nil {
+			first = nil
+		} else {
+			first = b.next
+			b.prev, b.next = nil, nil
+		}
+		a.prev, a.next = nil, nil
+		t := h.link(a, b)
+		t.next = pairs
+		pairs = t
+	}
+	var root *PairingNode
+	for pairs != nil {
+		next := pairs.next
+		pairs.next = nil
+		root = h.link(root, pairs)
+		pairs = next
+	}
+	return root
+}
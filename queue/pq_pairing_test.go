@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestPairingHeap(t *testing.T) {
+	h := NewPairingHeap(MinPriority)
+	nodes := make([]*PairingNode, 10)
+	for i := range nodes {
+		nodes[i] = h.PushValue(i, i+10)
+	}
+	tests := []struct {
+		node     int
+		priority int
+		ok       bool
+	}{
+		{7, 5, true},
+		{3, 13, true},
+		{2, 20, false},
+		{9, 1, true},
+		{0, 0, true},
+	}
+	for i, test := range tests {
+		if ok := h.DecreaseKey(nodes[test.node], test.priority); ok != test.ok {
+			t.Errorf("%d: expected %t, got %t", i, test.ok, ok)
+		}
+	}
+	for i, v := range []int{0, 9, 7, 8, 1, 2, 3, 4, 5, 6} {
+		// decreasing a key after some pops cuts a nested subtree
+		if i == 3 {
+			h.DecreaseKey(nodes[8], 10)
+		}
+		got, _, ok := h.PopValue()
+		if !ok || got != v {
+			t.Errorf("%d: expected %d, got %v (%t)", i, v, got, ok)
+		}
+	}
+	if h.DecreaseKey(nodes[0], -1) {
+		t.Error("expected DecreaseKey on a popped node to return false")
+	}
+}
+
+func TestPairingHeapMeld(t *testing.T) {
+	a, b := NewPairingHeap(nil), NewPairingHeap(nil)
+	for i := 0; i < 5; i++ {
+		a.PushValue(i*2, i*2)
+		b.PushValue(i*2+1, i*2+1)
+	}
+	n := b.PushValue("b", -1)
+	a.Meld(b)
+	if a.Len() != 11 || b.Len() != 0 {
+		t.Errorf("expected lens of 11 and 0, got %d and %d", a.Len(), b.Len())
+	}
+	// with a max-heap, decreasing the key raises the priority
+	a.DecreaseKey(n, 100)
+	if v, p, _ := a.PeekValue(); v != "b" || p != 100 {
+		t.Errorf("expected b 100, got %v %d", v, p)
+	}
+	_, _, _ = a.PopValue()
+	for i := 9; i >= 0; i-- {
+		if _, p, ok := a.PopValue(); !ok || p != i {
+			t.Errorf("expected %d, got %d (%t)", i, p, ok)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// ErrNotMonotone is returned when an item is pushed onto a RadixHeap with a
+// priority lower than that of the last popped item.
+var ErrNotMonotone = errors.New("priority is lower than the last popped priority")
+
+// radixItem is an item in a RadixHeap.
+type radixItem struct {
+	value    interface{}
+	priority int
+	key      uint64 // the priority, mapped to a uint64 that sorts the same
+}
+
+// RadixHeap is a thread-safe monotone priority queue for integer priorities:
+// the lowest priority is popped first and no item can be pushed with a
+// priority lower than that of the last popped item, as is the case for
+// e.g. Dijkstra's algorithm and event simulation. Items are kept in buckets
+// by the highest bit in which their priority differs from the last popped
+// priority, so pushes are O(1) and pops are amortized O(log C), where C is
+// the range of the priorities, instead of O(log n).
+type RadixHeap struct {
+	mu      sync.Mutex
+	buckets [65][]radixItem
+	last    uint64 // the key of the last popped item
+	n       int
+}
+
+// NewRadixHeap returns a new, empty, radix heap. Unlike HeapPriority, it pops
+// the lowest priority first; a HeapPriority, DAryHeap, or PairingHeap
+// created with MinPriority pops items in the same order.
+func NewRadixHeap() *RadixHeap {
+	return &RadixHeap{last: radixKey(math.MinInt)}
+}
+
+// radixKey maps a priority to a uint64 that sorts the same: flipping the
+// sign bit puts negative priorities below the positive ones.
+func radixKey(priority int) uint64 {
+	return uint64(priority) ^ (1 << 63)
+}
+
+// bucket returns the bucket for key: 0 if it equals the last popped key,
+// otherwise 1 plus the index of the highest bit in which they differ.
+func (h *RadixHeap) bucket(key uint64) int {
+	return bits.Len64(key ^ h.last)
+}
+
+// Insert pushes a value, with the received priority, onto the heap. If the
+// priority is lower than that of the last popped item, ErrNotMonotone is
+// returned.
+func (h *RadixHeap) Insert(value interface{}, priority int) error {
+	key := radixKey(priority)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if key < h.last {
+		return ErrNotMonotone
+	}
+	b := h.bucket(key)
+	h.buckets[b] = append(h.buckets[b], radixItem{value: value, priority: priority, key: key})
+	h.n++
+	return nil
+}
+
+// PopValue removes the lowest priority item and returns its value and
+// priority. If the heap is empty, a false will be returned.
+func (h *RadixHeap) PopValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.fill() {
+		return nil, 0, false
+	}
+	n := len(h.buckets[0]) - 1
+	item := h.buckets[0][n]
+	h.buckets[0][n] = radixItem{}
+	h.buckets[0] = h.buckets[0][:n]
+	h.n--
+	return item.value, item.priority, true
+}
+
+// PeekValue returns the value and priority of the lowest priority item
+// without removing it. If the heap is empty, a false will be returned.
+// Unlike PopValue, it doesn't redistribute the items: the lowest key would
+// become the last popped key, and items with lower priorities than the
+// peeked one could no longer be pushed. Instead, the first non-empty bucket
+// is scanned for its lowest priority item.
+func (h *RadixHeap) PeekValue() (interface{}, int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.n == 0 {
+		return nil, 0, false
+	}
+	if n := len(h.buckets[0]); n > 0 {
+		item := h.buckets[0][n-1]
+		return item.value, item.priority, true
+	}
+	i := 1
+	for len(h.buckets[i]) == 0 {
+		i++
+	}
+	item := h.buckets[i][0]
+	for _, it := range h.buckets[i][1:] {
+		if it.key < item.key {
+			item = it
+		}
+	}
+	return item.value, item.priority, true
+}
+
+// fill makes sure that bucket 0, which holds the items with the lowest
+// priority, isn't empty, if the heap isn't: the first non-empty bucket's
+// lowest key becomes the last popped key and the bucket's items are
+// redistributed, all to lower buckets. It returns false if the heap is
+// empty.
+func (h *RadixHeap) fill() bool {
+	if h.n == 0 {
+		return false
+	}
+	if len(h.buckets[0]) > 0 {
+		return true
+	}
+	i := 1
+	for len(h.buckets[i]) == 0 {
+		i++
+	}
+	items := h.buckets[i]
+	h.last = items[0].key
+	for _, item := range items[1:] {
+		h.last = min(h.last, item.key)
+	}
+	for _, item := range items {
+		b := h.bucket(item.key)
+		h.buckets[b] = append(h.buckets[b], item)
+	}
+	clear(items)
+	h.buckets[i] = items[:0]
+	return true
+}
+
+// Len returns the number of items in the heap.
+func (h *RadixHeap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.n
+}
+
+// Reset removes all of the items from the heap; any priority can then be
+// pushed.
+func (h *RadixHeap) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.buckets {
+		clear(h.buckets[i])
+		h.buckets[i] = h.buckets[i][:0]
+	}
+	h.last = radixKey(math.MinInt)
+	h.n = 0
+}
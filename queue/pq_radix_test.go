@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRadixHeap(t *testing.T) {
+	h := NewRadixHeap()
+	for _, p := range []int{5, -3, 1 << 40, 0, 5, math.MaxInt, math.MinInt, 17} {
+		if err := h.Insert(p, p); err != nil {
+			t.Errorf("%d: unexpected error: %q", p, err)
+		}
+	}
+	tests := []struct {
+		pop      bool
+		priority int
+		err      error
+	}{
+		{true, math.MinInt, nil},
+		{true, -3, nil},
+		{false, -4, ErrNotMonotone},
+		{false, -3, nil},
+		{true, -3, nil},
+		{true, 0, nil},
+		{true, 5, nil},
+		{false, 6, nil},
+		{true, 5, nil},
+		{true, 6, nil},
+		{false, 3, ErrNotMonotone},
+		{true, 17, nil},
+		{true, 1 << 40, nil},
+		{true, math.MaxInt, nil},
+	}
+	for i, test := range tests {
+		if !test.pop {
+			if err := h.Insert(test.priority, test.priority); err != test.err {
+				t.Errorf("%d: expected error %v, got %v", i, test.err, err)
+			}
+			continue
+		}
+		if _, p, ok := h.PeekValue(); !ok || p != test.priority {
+			t.Errorf("%d: expected to peek %d, got %d (%t)", i, test.priority, p, ok)
+		}
+		if v, p, ok := h.PopValue(); !ok || p != test.priority || v != p {
+			t.Errorf("%d: expected %d, got %v %d (%t)", i, test.priority, v, p, ok)
+		}
+	}
+	if _, _, ok := h.PopValue(); ok || h.Len() != 0 {
+		t.Errorf("expected the heap to be empty, len was %d", h.Len())
+	}
+	h.Reset()
+	if err := h.Insert(nil, -10); err != nil {
+		t.Errorf("expected any priority to be accepted after reset, got %v", err)
+	}
+}
+
+func TestRadixHeapPeekInsert(t *testing.T) {
+	h := NewRadixHeap()
+	_ = h.Insert(nil, 5)
+	_, _, _ = h.PopValue()
+	_ = h.Insert(nil, 10)
+	if _, p, ok := h.PeekValue(); !ok || p != 10 {
+		t.Errorf("expected to peek 10, got %d (%t)", p, ok)
+	}
+	// peeking doesn't raise the lowest priority that can be pushed
+	if err := h.Insert(nil, 7); err != nil {
+		t.Errorf("expected 7 to be pushed after peeking 10, got %v", err)
+	}
+	if err := h.Insert(nil, 4); err != ErrNotMonotone {
+		t.Errorf("expected %v, got %v", ErrNotMonotone, err)
+	}
+	for i, expected := range []int{7, 10} {
+		if _, p, ok := h.PopValue(); !ok || p != expected {
+			t.Errorf("%d: expected %d, got %d (%t)", i, expected, p, ok)
+		}
+	}
+}
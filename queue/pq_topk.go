@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+)
+
+// FullPolicy is what a TopK does with an item pushed when it is full.
+type FullPolicy int
+
+const (
+	// EvictWorst evicts the worst item if the new item is better than it;
+	// otherwise the new item is rejected.
+	EvictWorst FullPolicy = iota
+	// RejectNew rejects the new item.
+	RejectNew
+)
+
+// TopK is a thread-safe, bounded, priority queue that keeps the best k items
+// pushed onto it, using constant memory. Its heap is ordered worst item
+// first so that, when it is full, the worst item can be found in O(1) and
+// evicted in O(log n).
+type TopK struct {
+	mu      sync.Mutex
+	items   PQueue
+	h       heap.Interface // orders items worst first
+	less    func(a, b *Item) bool
+	k       int
+	policy  FullPolicy
+	onEvict func(value interface{}, priority int)
+	evicted uint64
+}
+
+// NewTopK returns a TopK that keeps the k items with the highest priority.
+func NewTopK(k int, policy FullPolicy) *TopK {
+	return NewTopKFunc(k, policy, nil)
+}
+
+// NewTopKFunc returns a TopK that keeps the best k items, as ordered by
+// less: if less(a, b) is true, a is better than b. A nil less is
+// MaxPriority.
+func NewTopKFunc(k int, policy FullPolicy, less func(a, b *Item) bool) *TopK {
+	if less == nil {
+		less = MaxPriority
+	}
+	t := &TopK{items: make(PQueue, 0, max(k, 0)), less: less, k: k, policy: policy}
+	t.h = lessHeap{PQueue: &t.items, less: func(a, b *Item) bool { return less(b, a) }}
+	return t
+}
+
+// SetOnEvict sets a function that is called with the value and priority of
+// each evicted item. It is called with the TopK's lock held, so it must not
+// call any of the TopK's methods.
+func (t *TopK) SetOnEvict(f func(value interface{}, priority int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onEvict = f
+}
+
+// Push pushes a value, with the received priority, onto the TopK and returns
+// whether or not it was added. If the TopK is full, the value is either
+// rejected or, if the policy is EvictWorst and the value is better than the
+// worst item, the worst item is evicted to make room for it.
+func (t *TopK) Push(value interface{}, priority int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	item := &Item{value: value, priority: priority}
+	if len(t.items) < t.k {
+		heap.Push(t.h, item)
+		return true
+	}
+	if t.policy == RejectNew || len(t.items) == 0 || !t.less(item, t.items[0]) {
+		return false
+	}
+	worst := t.items[0]
+	worst.index = -1
+	item.index = 0
+	t.items[0] = item
+	heap.Fix(t.h, 0)
+	t.evicted++
+	if t.onEvict != nil {
+		t.onEvict(worst.value, worst.priority)
+	}
+	return true
+}
+
+// Worst returns the value and priority of the worst item: the next one to be
+// evicted. If the TopK is empty, a false will be returned.
+func (t *TopK) Worst() (interface{}, int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.items) == 0 {
+		return nil, 0, false
+	}
+	return t.items[0].value, t.items[0].priority, true
+}
+
+// PopWorst removes the worst item and returns its value and priority. If the
+// TopK is empty, a false will be returned.
+func (t *TopK) PopWorst() (interface{}, int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.items) == 0 {
+		return nil, 0, false
+	}
+	item := heap.Pop(t.h).(*Item)
+	return item.value, item.priority, true
+}
+
+// Sorted returns the items in the TopK, best first. The TopK is not
+// modified.
+func (t *TopK) Sorted() []Prioritized {
+	t.mu.Lock()
+	items := slices.Clone(t.items)
+	t.mu.Unlock()
+	slices.SortStableFunc(items, func(a, b *Item) int {
+		switch {
+		case t.less(a, b):
+			return -1
+		case t.less(b, a):
+			return 1
+		}
+		return 0
+	})
+	sorted := make([]Prioritized, len(items))
+	for i, item := range items {
+		sorted[i] = Prioritized{Value: item.value, Priority: item.priority}
+	}
+	return sorted
+}
+
+// Evicted returns the number of items that have been evicted.
+func (t *TopK) Evicted() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.evicted
+}
+
+// IsFull returns whether or not the TopK holds k items.
+func (t *TopK) IsFull() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.items) >= t.k
+}
+
+// Len returns the number of items in the TopK.
+func (t *TopK) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.items)
+}
+
+// Cap returns k: the maximum number of items in the TopK.
+func (t *TopK) Cap() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.k
+}
+
+// Reset removes all of the items from the TopK. The eviction count is not
+// reset.
+func (t *TopK) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.items)
+	t.items = t.items[:0]
+}
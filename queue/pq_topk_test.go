@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	tests := []struct {
+		policy   FullPolicy
+		less     func(a, b *Item) bool
+		pushed   []int
+		added    []bool
+		expected []int
+		evicted  []int
+	}{
+		{EvictWorst, nil, []int{5, 1, 7, 3, 9, 2}, []bool{true, true, true, true, true, false}, []int{9, 7, 5}, []int{1, 3}},
+		{RejectNew, nil, []int{5, 1, 7, 3, 9, 2}, []bool{true, true, true, false, false, false}, []int{7, 5, 1}, nil},
+		{EvictWorst, MinPriority, []int{5, 1, 7, 3, 9, 2}, []bool{true, true, true, true, false, true}, []int{1, 2, 3}, []int{7, 5}},
+	}
+	for i, test := range tests {
+		topK := NewTopKFunc(3, test.policy, test.less)
+		var evicted []int
+		topK.SetOnEvict(func(value interface{}, priority int) { evicted = append(evicted, priority) })
+		for j, p := range test.pushed {
+			if added := topK.Push(p*10, p); added != test.added[j] {
+				t.Errorf("%d: push %d: expected added to be %t, got %t", i, j, test.added[j], added)
+			}
+		}
+		if !topK.IsFull() {
+			t.Errorf("%d: expected TopK to be full", i)
+		}
+		sorted := topK.Sorted()
+		if len(sorted) != len(test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, sorted)
+			continue
+		}
+		for j, p := range test.expected {
+			if sorted[j].Priority != p || sorted[j].Value != p*10 {
+				t.Errorf("%d: item %d: expected %d, got %v", i, j, p, sorted[j])
+			}
+		}
+		if topK.Evicted() != uint64(len(test.evicted)) || len(evicted) != len(test.evicted) {
+			t.Errorf("%d: expected evictions %v, got %v (%d)", i, test.evicted, evicted, topK.Evicted())
+			continue
+		}
+		for j, p := range test.evicted {
+			if evicted[j] != p {
+				t.Errorf("%d: eviction %d: expected %d, got %d", i, j, p, evicted[j])
+			}
+		}
+		_, p, ok := topK.Worst()
+		if !ok || p != test.expected[len(test.expected)-1] {
+			t.Errorf("%d: expected worst to be %d, got %d (%t)", i, test.expected[len(test.expected)-1], p, ok)
+		}
+	}
+}
+
+func TestTopKPopWorst(t *testing.T) {
+	topK := NewTopK(2, EvictWorst)
+	for i := 0; i < 10; i++ {
+		topK.Push(i, i)
+	}
+	for _, expected := range []int{8, 9} {
+		v, p, ok := topK.PopWorst()
+		if !ok || v != expected || p != expected {
+			t.Errorf("expected %d, got %v %d (%t)", expected, v, p, ok)
+		}
+	}
+	if _, _, ok := topK.PopWorst(); ok {
+		t.Error("expected the TopK to be empty")
+	}
+	topK.Push(1, 1)
+	topK.Reset()
+	if topK.Len() != 0 || topK.Cap() != 2 {
+		t.Errorf("expected len 0, cap 2 after reset, got %d, %d", topK.Len(), topK.Cap())
+	}
+}
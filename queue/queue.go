@@ -1,13 +1,27 @@
 // Package queue provides various thread-safe queue implementations: an
-// unbounded queue, a bounded queue implemented as a circular queue, and a
-// heap based priority queue.
+// unbounded queue, a bounded queue implemented as a circular queue, a
+// double-ended queue, lock-free bounded queues, and a heap based priority
+// queue.
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohae/firkin/codec"
 )
 
+// ErrClosed is returned when an item is added to a closed queue and when a
+// blocking dequeue is done on a queue that is closed and empty.
+var ErrClosed = errors.New("queue closed")
+
 // Queuer interface
 type Queuer interface {
 	Enqueue(item interface{}) error
@@ -37,6 +51,15 @@ type Queue struct {
 	Items        []interface{}
 	Head         int // current item in queue
 	shiftPercent int // the % of items that need to be removed before shifting occurs
+	notEmpty     *sync.Cond
+	notFull      *sync.Cond
+	closed       atomic.Bool
+	codec        codec.Codec
+	ttl          time.Duration // the default TTL of enqueued items; 0 for none
+	deadlines    []time.Time   // when each item in Items expires; nil if none can
+	onExpire     func(item interface{})
+	expired      uint64
+	clock        Clock
 }
 
 // NewQ is a convenience wrapper to NewQ().
@@ -72,40 +95,154 @@ func (q *Queue) SetShiftPercent(i int) {
 
 // Enqueue adds an item to the queue. If adding the item requires growing
 // the queue, the queue will either be shifted, to make room at the end of
-// the queue, or it will grow.
+// the queue, or it will grow. If the queue has a default TTL, the item
+// expires once it has elapsed.
 func (q *Queue) Enqueue(item interface{}) error {
 	q.Lock()
 	defer q.Unlock()
+	return q.enqueue(item, q.ttl)
+}
+
+// enqueue is an unexported version that expects the caller to handle
+// locking.
+func (q *Queue) enqueue(item interface{}, ttl time.Duration) error {
+	if q.IsClosed() {
+		return ErrClosed
+	}
 	// See if it needs to grow
 	if len(q.Items) == cap(q.Items) {
 		_ = q.shift()
 	}
 	q.Items = append(q.Items, item)
+	q.setDeadline(len(q.Items)-1, ttl)
+	q.notifyEnqueued()
 	return nil
 }
 
+// EnqueueWait adds an item to the queue. An unbounded queue always has room
+// for another item, so this never blocks; it exists to fulfill the same
+// contract as Circular.EnqueueWait. If ctx is already done, the item is not
+// enqueued and the context's error is returned. If the queue is closed,
+// ErrClosed is returned.
+func (q *Queue) EnqueueWait(ctx context.Context, item interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Enqueue(item)
+}
+
 // Dequeue removes an item from the queue. If the removal of the item empties
 // the queue, the head and tail will be set to 0. If the queue is empty, a
-// false will be returned, else true.
+// false will be returned, else true. Expired items are discarded.
 func (q *Queue) Dequeue() (interface{}, bool) {
 	q.Lock()
 	defer q.Unlock()
-	if q.isEmpty() {
-		return nil, false
+	item, ok := q.peek()
+	if ok {
+		q.Head++
+	}
+	return item, ok
+}
+
+// EnqueueMany adds the received items, in order, to the queue under a
+// single lock acquisition. If the items don't fit, the queue will either be
+// shifted, to make room at the end of the queue, or it will grow. The number
+// of items enqueued is returned; if the queue is closed, no items are
+// enqueued and ErrClosed is returned. If the queue has a default TTL, the
+// items expire once it has elapsed.
+func (q *Queue) EnqueueMany(items []interface{}) (int, error) {
+	q.Lock()
+	defer q.Unlock()
+	if q.IsClosed() {
+		return 0, ErrClosed
+	}
+	if len(q.Items)+len(items) > cap(q.Items) {
+		_ = q.shift()
+	}
+	q.Items = append(q.Items, items...)
+	if q.ttl > 0 || q.deadlines != nil {
+		for i := len(q.Items) - len(items); i < len(q.Items); i++ {
+			q.setDeadline(i, q.ttl)
+		}
+	}
+	if len(items) > 0 && q.notEmpty != nil {
+		q.notEmpty.Broadcast()
+	}
+	return len(items), nil
+}
+
+// DequeueN removes up to n items from the queue under a single lock
+// acquisition. The items are appended, in order, to dst and the resulting
+// slice is returned. Expired items are discarded and don't count towards n.
+func (q *Queue) DequeueN(n int, dst []interface{}) []interface{} {
+	q.Lock()
+	defer q.Unlock()
+	if q.deadlines != nil {
+		for ; n > 0; n-- {
+			item, ok := q.peek()
+			if !ok {
+				break
+			}
+			q.Head++
+			dst = append(dst, item)
+		}
+		return dst
+	}
+	if l := len(q.Items) - q.Head; n > l {
+		n = l
+	}
+	if n <= 0 {
+		return dst
+	}
+	dst = append(dst, q.Items[q.Head:q.Head+n]...)
+	q.Head += n
+	return dst
+}
+
+// DequeueWait removes an item from the queue. If the queue is empty, it
+// blocks until an item is enqueued or ctx is done, in which case the
+// context's error is returned. Once the queue is closed and empty,
+// ErrClosed is returned. Expired items are discarded.
+func (q *Queue) DequeueWait(ctx context.Context) (interface{}, error) {
+	q.Lock()
+	defer q.Unlock()
+	for {
+		err := q.wait(ctx, q.notEmptyCond(), func() bool { return !q.isEmpty() || q.IsClosed() })
+		if err != nil {
+			return nil, err
+		}
+		item, ok := q.peek()
+		if ok {
+			q.Head++
+			return item, nil
+		}
+		// either all of the items had expired or the queue is closed
+		if q.IsClosed() {
+			return nil, ErrClosed
+		}
 	}
-	q.Head++
-	return q.Items[q.Head-1], true
 }
 
 // Peek returns the next item in the queue. Post-peek, the queue remains the
-// same.
+// same, except that expired items at the head of the queue are discarded.
 func (q *Queue) Peek() (interface{}, bool) {
 	q.Lock()
 	defer q.Unlock()
-	if q.isEmpty() {
-		return nil, false
+	return q.peek()
+}
+
+// peek returns the item at the head of the queue, discarding any expired
+// items before it. The caller is expected to handle locking.
+func (q *Queue) peek() (interface{}, bool) {
+	for !q.isEmpty() {
+		item, ok := q.live(q.Head)
+		if ok {
+			return item, true
+		}
+		q.Items[q.Head] = nil
+		q.Head++
 	}
-	return q.Items[q.Head], true
+	return nil, false
 }
 
 // IsEmpty returns whether or not the queue is empty
@@ -131,7 +268,8 @@ func (q *Queue) IsFull() bool {
 	return false
 }
 
-// Len returns the current number of items in the queue
+// Len returns the current number of items in the queue. Expired items that
+// have not been discarded yet are included.
 func (q *Queue) Len() int {
 	q.Lock()
 	defer q.Unlock()
@@ -153,6 +291,9 @@ func (q *Queue) shift() bool {
 		return false
 	}
 	q.Items = append(q.Items[:0], q.Items[q.Head:]...)
+	if q.deadlines != nil {
+		q.deadlines = append(q.deadlines[:0], q.deadlines[q.Head:]...)
+	}
 	// set the pointers to the correct position
 	q.Head = 0
 	return true
@@ -165,6 +306,7 @@ func (q *Queue) Reset() {
 	q.Lock()
 	q.Head = 0
 	q.Items = q.Items[:0]
+	q.deadlines = nil
 	q.Unlock()
 }
 
@@ -186,9 +328,232 @@ func (q *Queue) Resize(size int) int {
 	// if necessary, shift Items to front.
 	if q.Head > 0 || len(q.Items) > 0 {
 		tmp = append(tmp, q.Items[q.Head:]...)
+		if q.deadlines != nil {
+			q.deadlines = append(make([]time.Time, 0, i), q.deadlines[q.Head:]...)
+		}
 		q.Head = 0
 	}
 	q.Items = tmp
 	q.Unlock()
 	return i
 }
+
+// Close closes the queue. Any further attempts to enqueue an item will fail
+// with ErrClosed. Items already in the queue can still be dequeued; once
+// they have all been dequeued, IsDrained returns true and DequeueWait
+// returns ErrClosed. Goroutines blocked in DequeueWait or EnqueueWait are
+// woken. Closing an already closed queue returns ErrClosed.
+func (q *Queue) Close() error {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed.Swap(true) {
+		return ErrClosed
+	}
+	q.notEmptyCond().Broadcast()
+	q.notFullCond().Broadcast()
+	return nil
+}
+
+// IsClosed returns whether or not the queue has been closed. It does not
+// take the queue's lock, so it can also be used by callers holding it.
+func (q *Queue) IsClosed() bool {
+	return q.closed.Load()
+}
+
+// IsDrained returns whether or not the queue is both closed and empty: no
+// more items will be dequeued from it.
+func (q *Queue) IsDrained() bool {
+	q.Lock()
+	defer q.Unlock()
+	return q.IsClosed() && q.isEmpty()
+}
+
+// notEmptyCond returns the condition that goroutines waiting for an item to
+// be enqueued wait on, creating it if necessary. The caller must hold the
+// lock. The conditions are created lazily, instead of in NewQueue, so that
+// they always refer to the lock of the Queue they belong to, even when the
+// Queue was copied into an embedding type, e.g. Circular.
+func (q *Queue) notEmptyCond() *sync.Cond {
+	if q.notEmpty == nil {
+		q.notEmpty = sync.NewCond(&q.Mutex)
+	}
+	return q.notEmpty
+}
+
+// notFullCond returns the condition that goroutines waiting for a free slot
+// wait on, creating it if necessary. The caller must hold the lock.
+func (q *Queue) notFullCond() *sync.Cond {
+	if q.notFull == nil {
+		q.notFull = sync.NewCond(&q.Mutex)
+	}
+	return q.notFull
+}
+
+// notifyEnqueued wakes a goroutine waiting in DequeueWait, if there is one.
+// The caller must hold the queue's lock.
+func (q *Queue) notifyEnqueued() {
+	if q.notEmpty != nil {
+		q.notEmpty.Signal()
+	}
+}
+
+// notifyDequeued wakes a goroutine waiting in EnqueueWait, if there is one.
+// The caller must hold the queue's lock.
+func (q *Queue) notifyDequeued() {
+	if q.notFull != nil {
+		q.notFull.Signal()
+	}
+}
+
+// wait blocks on c until ready returns true or ctx is done. The caller must
+// hold the queue's lock and c must use it. Cancellation of ctx wakes all of
+// c's waiters so that they can check their own contexts; ready is always
+// checked first so a goroutine that was signalled never drops the wakeup.
+func (q *Queue) wait(ctx context.Context, c *sync.Cond, ready func() bool) error {
+	if ready() {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() {
+		q.Lock()
+		c.Broadcast()
+		q.Unlock()
+	})
+	defer stop()
+	for !ready() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.Wait()
+	}
+	return nil
+}
+
+// All returns an iterator over the items in the queue, from head to tail.
+// The iterator ranges over a snapshot of the queue taken when iteration
+// starts; the queue is not locked while the items are yielded.
+func (q *Queue) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for _, item := range q.snapshot() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Indexed returns an iterator over the position and item of each item in
+// the queue, from head, position 0, to tail. Like All, it ranges over a
+// snapshot of the queue.
+func (q *Queue) Indexed() iter.Seq2[int, interface{}] {
+	return func(yield func(int, interface{}) bool) {
+		for i, item := range q.snapshot() {
+			if !yield(i, item) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues the items in the queue, yielding
+// each one, until the queue is empty. Items enqueued while draining are also
+// yielded. Stopping the iteration early leaves the remaining items in the
+// queue.
+func (q *Queue) Drain() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for {
+			item, ok := q.Dequeue()
+			if !ok || !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the items in the queue, from head to tail.
+// Expired items are left out.
+func (q *Queue) snapshot() []interface{} {
+	q.Lock()
+	defer q.Unlock()
+	return q.liveItems()
+}
+
+// liveItems is an unexported version of snapshot that expects the caller to
+// handle locking.
+func (q *Queue) liveItems() []interface{} {
+	if q.deadlines == nil {
+		return append([]interface{}(nil), q.Items[q.Head:]...)
+	}
+	tmp := make([]interface{}, 0, len(q.Items)-q.Head)
+	now := q.now()
+	for i := q.Head; i < len(q.Items); i++ {
+		if q.unexpired(i, now) {
+			tmp = append(tmp, q.Items[i])
+		}
+	}
+	return tmp
+}
+
+// SetCodec sets the codec used by Snapshot and Restore to encode and decode
+// the queue's items. By default, codec.Gob is used.
+func (q *Queue) SetCodec(c codec.Codec) {
+	q.Lock()
+	q.codec = c
+	q.Unlock()
+}
+
+// itemCodec returns the queue's codec. The caller is expected to handle
+// locking.
+func (q *Queue) itemCodec() codec.Codec {
+	if q.codec == nil {
+		return codec.Gob{}
+	}
+	return q.codec
+}
+
+// Snapshot writes the queue's items, from head to tail, along with its
+// capacity, initial capacity, and shift percent, to w. The items and the
+// configuration are copied under a single hold of the lock, so that they
+// match, and encoded after it is released. Expired items are left out and
+// the expiry of the others is not kept.
+func (q *Queue) Snapshot(w io.Writer) error {
+	q.Lock()
+	s := &codec.Snapshot{
+		Kind:         "queue",
+		Cap:          cap(q.Items),
+		InitCap:      q.InitCap,
+		ShiftPercent: q.shiftPercent,
+		Items:        q.liveItems(),
+	}
+	c := q.itemCodec()
+	q.Unlock()
+	return codec.Encode(w, c, s)
+}
+
+// Restore replaces the queue's items and configuration with those of a
+// snapshot written by Snapshot. Any items in the queue will be lost.
+func (q *Queue) Restore(r io.Reader) error {
+	q.Lock()
+	c := q.itemCodec()
+	q.Unlock()
+	s, err := codec.Decode(r, c)
+	if err != nil {
+		return err
+	}
+	if s.Kind != "queue" {
+		return fmt.Errorf("cannot restore a %s snapshot to a queue", s.Kind)
+	}
+	if len(s.Items) > s.Cap {
+		return fmt.Errorf("cannot restore %d items to a queue with a capacity of %d", len(s.Items), s.Cap)
+	}
+	q.Lock()
+	defer q.Unlock()
+	q.InitCap = s.InitCap
+	q.shiftPercent = s.ShiftPercent
+	q.Items = append(make([]interface{}, 0, s.Cap), s.Items...)
+	q.deadlines = nil
+	q.Head = 0
+	if q.notEmpty != nil {
+		q.notEmpty.Broadcast()
+	}
+	return nil
+}
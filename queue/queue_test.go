@@ -1,7 +1,14 @@
 package queue
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"testing"
+	"time"
+
+	"github.com/mohae/firkin/codec"
 )
 
 func TestNew(t *testing.T) {
@@ -306,3 +313,283 @@ func TestQueueResetResize(t *testing.T) {
 		}
 	}
 }
+
+func TestQueueDequeueWait(t *testing.T) {
+	q := NewQ(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	v, err := q.DequeueWait(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %q, got %v: %v", context.DeadlineExceeded, v, err)
+	}
+
+	done := make(chan interface{})
+	go func() {
+		v, err := q.DequeueWait(context.Background())
+		if err != nil {
+			t.Errorf("unexpected error: %q", err)
+		}
+		done <- v
+	}()
+	time.Sleep(5 * time.Millisecond)
+	err = q.EnqueueWait(context.Background(), 42)
+	if err != nil {
+		t.Errorf("unexpected error: %q", err)
+	}
+	if v := <-done; v != 42 {
+		t.Errorf("expected 42, got %v", v)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err = q.EnqueueWait(ctx, 1); err != context.Canceled {
+		t.Errorf("expected %q, got %v", context.Canceled, err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected queue to be empty, got %d items", q.Len())
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQ(4)
+	_ = q.Enqueue(0)
+	_ = q.Enqueue(1)
+	done := make(chan error)
+	go func() {
+		// drains the queue, then blocks until the queue is closed
+		var err error
+		for err == nil {
+			_, err = q.DequeueWait(context.Background())
+		}
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if err := q.Close(); err != nil {
+		t.Errorf("unexpected error: %q", err)
+	}
+	if err := <-done; err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+	if err := q.Enqueue(2); err != ErrClosed {
+		t.Errorf("expected %q, got %v", ErrClosed, err)
+	}
+	if !q.IsDrained() {
+		t.Error("expected queue to be drained")
+	}
+	if err := q.Close(); err != ErrClosed {
+		t.Errorf("expected second close to return %q, got %v", ErrClosed, err)
+	}
+}
+
+func TestQueueEnqueueManyDequeueN(t *testing.T) {
+	tests := []struct {
+		size     int
+		pre      int // items enqueued and dequeued first
+		items    []interface{}
+		n        int
+		expected []interface{}
+		len      int
+	}{
+		{4, 0, []interface{}{}, 2, []interface{}{}, 0},
+		{4, 0, []interface{}{0, 1, 2}, 2, []interface{}{0, 1}, 1},
+		{4, 0, []interface{}{0, 1, 2, 3, 4, 5}, 10, []interface{}{0, 1, 2, 3, 4, 5}, 0},
+		{4, 3, []interface{}{0, 1, 2}, 3, []interface{}{0, 1, 2}, 0},
+		{4, 0, []interface{}{0, 1}, 0, []interface{}{}, 2},
+	}
+	for i, test := range tests {
+		q := NewQ(test.size)
+		for j := 0; j < test.pre; j++ {
+			_ = q.Enqueue(j)
+			_, _ = q.Dequeue()
+		}
+		n, err := q.EnqueueMany(test.items)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %q", i, err)
+		}
+		if n != len(test.items) {
+			t.Errorf("%d: expected %d items to be enqueued, got %d", i, len(test.items), n)
+		}
+		got := q.DequeueN(test.n, nil)
+		if len(got) != len(test.expected) {
+			t.Errorf("%d: expected %d items to be dequeued, got %d", i, len(test.expected), len(got))
+			continue
+		}
+		for j, v := range test.expected {
+			if got[j] != v {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, v, got[j])
+			}
+		}
+		if q.Len() != test.len {
+			t.Errorf("%d: expected len to be %d, got %d", i, test.len, q.Len())
+		}
+	}
+}
+
+func TestQueueIterators(t *testing.T) {
+	q := NewQ(4)
+	for i := 0; i < 6; i++ {
+		_ = q.Enqueue(i)
+	}
+	_, _ = q.Dequeue()
+	var got []interface{}
+	for v := range q.All() {
+		got = append(got, v)
+		// the iterator ranges over a snapshot, so this doesn't deadlock
+		_ = q.Enqueue(100)
+	}
+	if len(got) != 5 {
+		t.Fatalf("expected 5 items, got %v", got)
+	}
+	for i, v := range got {
+		if v != i+1 {
+			t.Errorf("All: item %d: expected %d, got %v", i, i+1, v)
+		}
+	}
+	for i, v := range q.Indexed() {
+		if i < 5 && v != i+1 {
+			t.Errorf("Indexed: item %d: expected %d, got %v", i, i+1, v)
+		}
+		if i == 2 {
+			break
+		}
+	}
+	n := 0
+	for v := range q.Drain() {
+		if n < 5 && v != n+1 {
+			t.Errorf("Drain: item %d: expected %d, got %v", n, n+1, v)
+		}
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if q.Len() != 7 {
+		t.Errorf("expected 7 items to remain after stopping Drain, got %d", q.Len())
+	}
+	for range q.Drain() {
+	}
+	if !q.IsEmpty() {
+		t.Errorf("expected queue to be empty, len was %d", q.Len())
+	}
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	items := []interface{}{0, 1, 2}
+	tests := []struct {
+		snap *codec.Snapshot
+		r    interface{ Restore(io.Reader) error }
+		bad  bool // rejected by codec.Decode
+	}{
+		{&codec.Snapshot{Kind: "queue", Cap: -1}, NewQ(2), true},
+		{&codec.Snapshot{Kind: "queue", Cap: 1 << 40}, NewQ(2), true},
+		{&codec.Snapshot{Kind: "queue", Cap: 2, Items: items}, NewQ(2), false},
+		{&codec.Snapshot{Kind: "circular", Cap: -3}, NewCircular(2), true},
+		{&codec.Snapshot{Kind: "circular", Cap: 3, Items: items}, NewCircular(2), false},
+		{&codec.Snapshot{Kind: "heap", Cap: 1 << 40}, NewHeapPriority(2), true},
+		{&codec.Snapshot{Kind: "heap", Cap: 2, Items: items, Priorities: []int{0, 1, 2}}, NewHeapPriority(2), false},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := codec.Encode(&buf, codec.Gob{}, test.snap); err != nil {
+			t.Fatalf("%d: unexpected error: %q", i, err)
+		}
+		err := test.r.Restore(&buf)
+		if err == nil {
+			t.Errorf("%d: expected an error restoring a corrupt snapshot, got nil", i)
+			continue
+		}
+		if errors.Is(err, codec.ErrBadSnapshot) != test.bad {
+			t.Errorf("%d: expected ErrBadSnapshot to be %t, got %v", i, test.bad, err)
+		}
+	}
+}
+
+func TestQueueSnapshotRestore(t *testing.T) {
+	q := NewQ(2)
+	q.SetShiftPercent(20)
+	for i := 0; i < 5; i++ {
+		_ = q.Enqueue(i)
+	}
+	_, _ = q.Dequeue()
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r := NewQ(10)
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	if r.InitCap != 2 || r.shiftPercent != 20 || r.Cap() != q.Cap() {
+		t.Errorf("expected InitCap 2, shiftPercent 20, cap %d; got %d, %d, %d", q.Cap(), r.InitCap, r.shiftPercent, r.Cap())
+	}
+	for i := 1; i < 5; i++ {
+		v, ok := r.Dequeue()
+		if !ok || v != i {
+			t.Errorf("expected %d, got %v (%t)", i, v, ok)
+		}
+	}
+	if !r.IsEmpty() {
+		t.Errorf("expected restored queue to be empty, len was %d", r.Len())
+	}
+	buf.Reset()
+	_ = NewCircular(2).Snapshot(&buf)
+	if err := r.Restore(&buf); err == nil || err.Error() != "cannot restore a circular snapshot to a queue" {
+		t.Errorf("expected kind mismatch error, got %v", err)
+	}
+}
+
+func TestQueueTTL(t *testing.T) {
+	clock := newFakeClock()
+	q := NewQ(4)
+	q.SetClock(clock)
+	var expired []interface{}
+	q.SetOnExpire(func(item interface{}) { expired = append(expired, item) })
+	_ = q.EnqueueTTL(0, time.Second)
+	_ = q.Enqueue(1)
+	_ = q.EnqueueTTL(2, time.Second)
+	_ = q.EnqueueTTL(3, 3*time.Second)
+	// items with a TTL are stored as is
+	for i, v := range q.Items {
+		if v != i {
+			t.Errorf("%d: expected Items to hold %d, got %v", i, i, v)
+		}
+	}
+	v, ok := q.Peek()
+	if !ok || v != 0 {
+		t.Errorf("expected 0, got %v (%t)", v, ok)
+	}
+	clock.Advance(time.Second)
+	tests := []struct {
+		expected interface{}
+		ok       bool
+	}{
+		{1, true},
+		{3, true},
+		{nil, false},
+	}
+	for i, test := range tests {
+		v, ok := q.Dequeue()
+		if v != test.expected || ok != test.ok {
+			t.Errorf("%d: expected %v (%t), got %v (%t)", i, test.expected, test.ok, v, ok)
+		}
+	}
+	if q.Expired() != 2 {
+		t.Errorf("expected 2 expired items, got %d", q.Expired())
+	}
+	if len(expired) != 2 || expired[0] != 0 || expired[1] != 2 {
+		t.Errorf("expected OnExpire to be called with [0 2], got %v", expired)
+	}
+	// the default TTL applies to Enqueue and EnqueueMany
+	q.SetTTL(time.Second)
+	_ = q.Enqueue(4)
+	_, _ = q.EnqueueMany([]interface{}{5, 6})
+	_ = q.EnqueueTTL(7, 0)
+	clock.Advance(time.Second)
+	got := q.DequeueN(4, nil)
+	if len(got) != 1 || got[0] != 7 {
+		t.Errorf("expected [7], got %v", got)
+	}
+	if q.Expired() != 5 {
+		t.Errorf("expected 5 expired items, got %d", q.Expired())
+	}
+}
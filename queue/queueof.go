@@ -0,0 +1,174 @@
+package queue
+
+import "sync"
+
+// QueuerOf is the type-parameterized counterpart of Queuer. Items are stored
+// as T instead of interface{}, which avoids boxing the items and the type
+// assertions on their retrieval.
+type QueuerOf[T any] interface {
+	Enqueue(item T) error
+	Dequeue() (T, bool)
+	Peek() (T, bool)
+	IsEmpty() bool
+	IsFull() bool
+	Len() int
+	Cap() int
+	Reset()
+	Resize(int) int
+}
+
+// QueueOf represents an unbounded queue of T and everything needed to manage
+// it. It has the same semantics as Queue. The preferred method for creating
+// a new QueueOf is to use NewQueueOf().
+type QueueOf[T any] struct {
+	sync.Mutex
+	InitCap      int
+	Items        []T
+	Head         int // current item in queue
+	shiftPercent int // the % of items that need to be removed before shifting occurs
+}
+
+// NewQueueOf returns an empty queue of T with an initial capacity equal to
+// the received size.
+func NewQueueOf[T any](size int) *QueueOf[T] {
+	return &QueueOf[T]{InitCap: size, Items: make([]T, 0, size), shiftPercent: shiftPercent}
+}
+
+// SetShiftPercent sets the queue's shiftPercent: the percentage of the queue
+// that must be empty before the remaining items will be shifted to the
+// the beginning of the slice. This occurs when the slice is set to grow.
+//
+// Valid range of values are 0-100, inclusive. Vaues < 0 are set to 0 and
+// values > 100 are set to 100.
+func (q *QueueOf[T]) SetShiftPercent(i int) {
+	q.Lock()
+	defer q.Unlock()
+	if i < 0 {
+		q.shiftPercent = 0
+		return
+	}
+	if i > 100 {
+		q.shiftPercent = 100
+		return
+	}
+	q.shiftPercent = i
+}
+
+// Enqueue adds an item to the queue. If adding the item requires growing
+// the queue, the queue will either be shifted, to make room at the end of
+// the queue, or it will grow.
+func (q *QueueOf[T]) Enqueue(item T) error {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.Items) == cap(q.Items) {
+		_ = q.shift()
+	}
+	q.Items = append(q.Items, item)
+	return nil
+}
+
+// Dequeue removes an item from the queue. If the queue is empty, the zero
+// value of T and a false will be returned, else true. The vacated slot is
+// zeroed so that the queue does not keep the item alive.
+func (q *QueueOf[T]) Dequeue() (T, bool) {
+	q.Lock()
+	defer q.Unlock()
+	var zero T
+	if q.isEmpty() {
+		return zero, false
+	}
+	item := q.Items[q.Head]
+	q.Items[q.Head] = zero
+	q.Head++
+	return item, true
+}
+
+// Peek returns the next item in the queue. Post-peek, the queue remains the
+// same.
+func (q *QueueOf[T]) Peek() (T, bool) {
+	q.Lock()
+	defer q.Unlock()
+	if q.isEmpty() {
+		var zero T
+		return zero, false
+	}
+	return q.Items[q.Head], true
+}
+
+// IsEmpty returns whether or not the queue is empty
+func (q *QueueOf[T]) IsEmpty() bool {
+	q.Lock()
+	defer q.Unlock()
+	return q.isEmpty()
+}
+
+// isEmpty is an unexported version that doesn't lock because the caller
+// will have handled that.
+func (q *QueueOf[T]) isEmpty() bool {
+	return q.Head == len(q.Items)
+}
+
+// IsFull returns false; this is implemented to fulfill QueuerOf but a
+// dynamic queue will never be full.
+func (q *QueueOf[T]) IsFull() bool {
+	return false
+}
+
+// Len returns the current number of items in the queue
+func (q *QueueOf[T]) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.Items) - q.Head
+}
+
+// Cap returns the current size of the queue
+func (q *QueueOf[T]) Cap() int {
+	q.Lock()
+	defer q.Unlock()
+	return cap(q.Items)
+}
+
+// shift: if shiftPercent Items have been removed from the queue, the
+// remaining items in the queue will be shifted to the beginning of the
+// queue. Returns whether or not a shift occurred.
+func (q *QueueOf[T]) shift() bool {
+	if q.Head < (cap(q.Items)*q.shiftPercent)/100 {
+		return false
+	}
+	n := copy(q.Items, q.Items[q.Head:])
+	clear(q.Items[n:])
+	q.Items = q.Items[:n]
+	q.Head = 0
+	return true
+}
+
+// Reset resets the queue; Head points to element 0. This does not shrink the
+// queue; for that use Resize(). Any items in the queue will be lost.
+func (q *QueueOf[T]) Reset() {
+	q.Lock()
+	clear(q.Items)
+	q.Head = 0
+	q.Items = q.Items[:0]
+	q.Unlock()
+}
+
+// Resize resizes the queue to the received size, or, either its original
+// capacity or to 1.25 * the number of items in the queue, whichever is
+// larger. When a size of 0 is received, the queue will be set to either 1.25
+// * the number of items in the queue or its initial capacity, whichever is
+// larger. Queues with space at the front are shifted to the front.
+func (q *QueueOf[T]) Resize(size int) int {
+	q.Lock()
+	defer q.Unlock()
+	i := int(float64(len(q.Items)-q.Head) * 1.25)
+	if i < q.InitCap {
+		i = q.InitCap
+	}
+	if size > i {
+		i = size
+	}
+	tmp := make([]T, 0, i)
+	q.Items = append(tmp, q.Items[q.Head:]...)
+	q.Head = 0
+	return i
+}
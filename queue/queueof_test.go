@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"testing"
+)
+
+var _ QueuerOf[int] = (*QueueOf[int])(nil)
+var _ QueuerOf[int] = (*CircularOf[int])(nil)
+
+func TestQueueOf(t *testing.T) {
+	tests := []struct {
+		size        int
+		items       []int
+		dequeue     int
+		expectedLen int
+		expectedCap int
+		peek        int
+		peekOk      bool
+	}{
+		{2, []int{}, 0, 0, 2, 0, false},
+		{2, []int{0, 1}, 0, 2, 2, 0, true},
+		{2, []int{0, 1, 2, 3, 4}, 0, 5, 8, 0, true},
+		{2, []int{0, 1, 2, 3, 4}, 3, 2, 8, 3, true},
+		{2, []int{0, 1, 2, 3, 4}, 5, 0, 8, 0, false},
+	}
+	for i, test := range tests {
+		q := NewQueueOf[int](test.size)
+		for _, v := range test.items {
+			_ = q.Enqueue(v)
+		}
+		for j := 0; j < test.dequeue; j++ {
+			v, ok := q.Dequeue()
+			if !ok {
+				t.Errorf("%d: dequeue #%d: expected ok to be true, got false", i, j)
+			}
+			if v != test.items[j] {
+				t.Errorf("%d: dequeue #%d: expected %d, got %d", i, j, test.items[j], v)
+			}
+		}
+		if q.Len() != test.expectedLen {
+			t.Errorf("%d: expected len to be %d, got %d", i, test.expectedLen, q.Len())
+		}
+		if q.Cap() != test.expectedCap {
+			t.Errorf("%d: expected cap to be %d, got %d", i, test.expectedCap, q.Cap())
+		}
+		v, ok := q.Peek()
+		if ok != test.peekOk {
+			t.Errorf("%d: expected peek ok to be %t, got %t", i, test.peekOk, ok)
+		}
+		if v != test.peek {
+			t.Errorf("%d: expected peek to return %d, got %d", i, test.peek, v)
+		}
+		if q.IsEmpty() != (test.expectedLen == 0) {
+			t.Errorf("%d: expected IsEmpty to be %t, got %t", i, test.expectedLen == 0, q.IsEmpty())
+		}
+	}
+}
+
+func TestQueueOfShift(t *testing.T) {
+	q := NewQueueOf[int](10)
+	for i := 0; i < 10; i++ {
+		_ = q.Enqueue(i)
+	}
+	for i := 0; i < 5; i++ {
+		_, _ = q.Dequeue()
+	}
+	_ = q.Enqueue(10)
+	if q.Head != 0 {
+		t.Errorf("expected head to be at pos 0, got %d", q.Head)
+	}
+	if q.Cap() != 10 {
+		t.Errorf("expected cap to be 10, got %d", q.Cap())
+	}
+	for i := 5; i <= 10; i++ {
+		v, _ := q.Dequeue()
+		if v != i {
+			t.Errorf("expected %d, got %d", i, v)
+		}
+	}
+}
+
+func TestQueueOfResetResize(t *testing.T) {
+	tests := []struct {
+		size        int
+		enqueue     int
+		dequeue     int
+		resize      int
+		expectedLen int
+		expectedCap int
+	}{
+		{4, 0, 0, 0, 0, 4},
+		{2, 2, 0, 0, 2, 2},
+		{2, 5, 0, 0, 5, 6},
+		{2, 5, 5, 0, 0, 2},
+		{2, 5, 5, 4, 0, 4},
+		{2, 6, 1, 0, 5, 6},
+		{2, 6, 1, 7, 5, 7},
+	}
+	for i, test := range tests {
+		q := NewQueueOf[int](test.size)
+		for j := 0; j < test.enqueue; j++ {
+			_ = q.Enqueue(j)
+		}
+		for j := 0; j < test.dequeue; j++ {
+			_, _ = q.Dequeue()
+		}
+		q.Resize(test.resize)
+		if q.Len() != test.expectedLen {
+			t.Errorf("%d: after Resize(), expected len to be %d, got %d", i, test.expectedLen, q.Len())
+		}
+		if q.Head != 0 {
+			t.Errorf("%d: after Resize(), expected head to be at pos 0, got %d", i, q.Head)
+		}
+		if q.Cap() != test.expectedCap {
+			t.Errorf("%d: after Resize(), expected cap to be %d, got %d", i, test.expectedCap, q.Cap())
+		}
+		for j := test.dequeue; j < test.enqueue; j++ {
+			v, _ := q.Dequeue()
+			if v != j {
+				t.Errorf("%d: after Resize(), expected %d, got %d", i, j, v)
+			}
+		}
+		q.Reset()
+		if q.Len() != 0 {
+			t.Errorf("%d: after Reset(), expected len to be 0, got %d", i, q.Len())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package queue
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SPSC is a wait-free, bounded, single-producer/single-consumer queue. Only
+// one goroutine may enqueue to it and only one goroutine may dequeue from it;
+// they may be different goroutines. With that restriction, no lock and no
+// compare-and-swap is needed: the producer only stores the tail and the
+// consumer only stores the head.
+//
+// Each side also keeps a cached copy of the other side's index and only
+// loads the shared index when the cached copy says the queue is full, or
+// empty. This keeps the cache line holding the other side's index from
+// being transferred between CPUs on every operation.
+//
+// Enqueue and EnqueueBatch may only be called by the producer; Dequeue,
+// DequeueBatch, and Peek may only be called by the consumer. Reset must only
+// be called when neither side is active. Len, IsEmpty, IsFull, and Cap may
+// be called by any goroutine.
+type SPSC struct {
+	_          cacheLinePad
+	head       atomic.Uint64 // next position to dequeue from; stored by the consumer
+	cachedTail uint64        // the consumer's copy of tail
+	_          cacheLinePad
+	tail       atomic.Uint64 // next position to enqueue to; stored by the producer
+	cachedHead uint64        // the producer's copy of head
+	_          cacheLinePad
+	mask       uint64
+	items      []interface{}
+}
+
+// NewSPSC returns an empty SPSC queue whose capacity is size rounded up to
+// the next power of two.
+func NewSPSC(size int) *SPSC {
+	n := ceilPow2(size)
+	return &SPSC{mask: n - 1, items: make([]interface{}, n)}
+}
+
+// Enqueue adds an item to the queue. If the queue is full, an error is
+// returned and the item is not added.
+func (q *SPSC) Enqueue(item interface{}) error {
+	t := q.tail.Load()
+	if t-q.cachedHead == uint64(len(q.items)) {
+		q.cachedHead = q.head.Load()
+		if t-q.cachedHead == uint64(len(q.items)) {
+			return fmt.Errorf("queue full: cannot enqueue %v", item)
+		}
+	}
+	q.items[t&q.mask] = item
+	q.tail.Store(t + 1)
+	return nil
+}
+
+// EnqueueBatch adds as many of the received items to the queue as will fit,
+// in order, and returns the number of items added. The tail is published
+// once for the whole batch.
+func (q *SPSC) EnqueueBatch(items []interface{}) int {
+	t := q.tail.Load()
+	free := uint64(len(q.items)) - (t - q.cachedHead)
+	if free < uint64(len(items)) {
+		q.cachedHead = q.head.Load()
+		free = uint64(len(q.items)) - (t - q.cachedHead)
+	}
+	n := uint64(len(items))
+	if n > free {
+		n = free
+	}
+	for i := uint64(0); i < n; i++ {
+		q.items[(t+i)&q.mask] = items[i]
+	}
+	if n > 0 {
+		q.tail.Store(t + n)
+	}
+	return int(n)
+}
+
+// Dequeue removes the next item from the queue and returns it. If the queue
+// is empty, a false will be returned.
+func (q *SPSC) Dequeue() (interface{}, bool) {
+	h := q.head.Load()
+	if h == q.cachedTail {
+		q.cachedTail = q.tail.Load()
+		if h == q.cachedTail {
+			return nil, false
+		}
+	}
+	item := q.items[h&q.mask]
+	q.items[h&q.mask] = nil
+	q.head.Store(h + 1)
+	return item, true
+}
+
+// DequeueBatch removes up to len(dst) items from the queue, copying them, in
+// order, to dst, and returns the number of items removed. The head is
+// published once for the whole batch.
+func (q *SPSC) DequeueBatch(dst []interface{}) int {
+	h := q.head.Load()
+	if q.cachedTail-h < uint64(len(dst)) {
+		q.cachedTail = q.tail.Load()
+	}
+	n := q.cachedTail - h
+	if n > uint64(len(dst)) {
+		n = uint64(len(dst))
+	}
+	for i := uint64(0); i < n; i++ {
+		dst[i] = q.items[(h+i)&q.mask]
+		q.items[(h+i)&q.mask] = nil
+	}
+	if n > 0 {
+		q.head.Store(h + n)
+	}
+	return int(n)
+}
+
+// Peek returns the next item in the queue without removing it. If the queue
+// is empty, a false will be returned.
+func (q *SPSC) Peek() (interface{}, bool) {
+	h := q.head.Load()
+	if h == q.cachedTail {
+		q.cachedTail = q.tail.Load()
+		if h == q.cachedTail {
+			return nil, false
+		}
+	}
+	return q.items[h&q.mask], true
+}
+
+// IsEmpty returns whether or not the queue is empty.
+func (q *SPSC) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// IsFull returns whether or not the queue is full.
+func (q *SPSC) IsFull() bool {
+	return q.Len() == len(q.items)
+}
+
+// Len returns the number of items in the queue.
+func (q *SPSC) Len() int {
+	h := q.head.Load()
+	return int(q.tail.Load() - h)
+}
+
+// Cap returns the capacity of the queue.
+func (q *SPSC) Cap() int {
+	return len(q.items)
+}
+
+// Reset removes all items from the queue. It must only be called when
+// neither the producer nor the consumer is using the queue.
+func (q *SPSC) Reset() {
+	clear(q.items)
+	t := q.tail.Load()
+	q.head.Store(t)
+	q.cachedHead = t
+	q.cachedTail = t
+}
+
+// Resize is implemented to fulfill Queuer. The producer and consumer use the
+// slots without any synchronization between them, so an SPSC queue cannot
+// be resized; its capacity is returned unchanged.
+func (q *SPSC) Resize(size int) int {
+	return len(q.items)
+}
@@ -0,0 +1,158 @@
+package queue
+
+import (
+	"runtime"
+	"testing"
+)
+
+var _ Queuer = (*SPSC)(nil)
+
+func TestSPSC(t *testing.T) {
+	q := NewSPSC(3)
+	if q.Cap() != 4 {
+		t.Errorf("expected cap to be 4, got %d", q.Cap())
+	}
+	if _, ok := q.Dequeue(); ok {
+		t.Error("expected dequeue on an empty queue to return false")
+	}
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 4; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Errorf("%d: enqueue %d: unexpected error: %q", lap, i, err)
+			}
+		}
+		if !q.IsFull() {
+			t.Errorf("%d: expected queue to be full, len was %d", lap, q.Len())
+		}
+		err := q.Enqueue(4)
+		if err == nil || err.Error() != "queue full: cannot enqueue 4" {
+			t.Errorf("%d: expected queue full error, got %v", lap, err)
+		}
+		v, ok := q.Peek()
+		if !ok || v != 0 {
+			t.Errorf("%d: expected peek to return 0, got %v", lap, v)
+		}
+		for i := 0; i < 4; i++ {
+			v, ok := q.Dequeue()
+			if !ok || v != i {
+				t.Errorf("%d: expected %d, got %v (%t)", lap, i, v, ok)
+			}
+		}
+		if !q.IsEmpty() {
+			t.Errorf("%d: expected queue to be empty, len was %d", lap, q.Len())
+		}
+	}
+	_ = q.Enqueue(1)
+	q.Reset()
+	if !q.IsEmpty() {
+		t.Errorf("after Reset(), expected queue to be empty, len was %d", q.Len())
+	}
+}
+
+func TestSPSCBatch(t *testing.T) {
+	tests := []struct {
+		size     int
+		pre      int // items enqueued and dequeued before the batch, to wrap
+		enqueue  []interface{}
+		enqueued int
+		dst      int
+		dequeued int
+	}{
+		{4, 0, []interface{}{0, 1}, 2, 4, 2},
+		{4, 0, []interface{}{0, 1, 2, 3, 4, 5}, 4, 4, 4},
+		{4, 3, []interface{}{0, 1, 2}, 3, 2, 2},
+		{4, 3, []interface{}{0, 1, 2, 3, 4}, 4, 8, 4},
+		{4, 0, []interface{}{}, 0, 4, 0},
+	}
+	for i, test := range tests {
+		q := NewSPSC(test.size)
+		for j := 0; j < test.pre; j++ {
+			_ = q.Enqueue(j)
+			_, _ = q.Dequeue()
+		}
+		n := q.EnqueueBatch(test.enqueue)
+		if n != test.enqueued {
+			t.Errorf("%d: expected %d items to be enqueued, got %d", i, test.enqueued, n)
+		}
+		dst := make([]interface{}, test.dst)
+		n = q.DequeueBatch(dst)
+		if n != test.dequeued {
+			t.Errorf("%d: expected %d items to be dequeued, got %d", i, test.dequeued, n)
+		}
+		for j := 0; j < n; j++ {
+			if dst[j] != test.enqueue[j] {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, test.enqueue[j], dst[j])
+			}
+		}
+		if q.Len() != test.enqueued-test.dequeued {
+			t.Errorf("%d: expected len to be %d, got %d", i, test.enqueued-test.dequeued, q.Len())
+		}
+	}
+}
+
+func TestSPSCConcurrent(t *testing.T) {
+	const n = 10000
+	q := NewSPSC(16)
+	go func() {
+		for i := 0; i < n; i++ {
+			for q.Enqueue(i) != nil {
+				runtime.Gosched()
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		v, ok := q.Dequeue()
+		for !ok {
+			runtime.Gosched()
+			v, ok = q.Dequeue()
+		}
+		if v != i {
+			t.Fatalf("expected %d, got %v", i, v)
+		}
+	}
+}
+
+func BenchmarkSPSC(b *testing.B) {
+	q := NewSPSC(1024)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			for q.Enqueue(i) != nil {
+				runtime.Gosched()
+			}
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		for {
+			if _, ok := q.Dequeue(); ok {
+				break
+			}
+			runtime.Gosched()
+		}
+	}
+}
+
+func BenchmarkSPSCBatch(b *testing.B) {
+	const batch = 64
+	q := NewSPSC(1024)
+	items := make([]interface{}, batch)
+	for i := range items {
+		items[i] = i
+	}
+	go func() {
+		for i := 0; i < b.N; {
+			n := q.EnqueueBatch(items[:min(batch, b.N-i)])
+			if n == 0 {
+				runtime.Gosched()
+			}
+			i += n
+		}
+	}()
+	dst := make([]interface{}, batch)
+	for i := 0; i < b.N; {
+		n := q.DequeueBatch(dst)
+		if n == 0 {
+			runtime.Gosched()
+		}
+		i += n
+	}
+}
@@ -0,0 +1,108 @@
+package queue
+
+import "time"
+
+// EnqueueTTL adds an item to the queue that expires once ttl has elapsed.
+// Expired items are discarded, instead of returned, by Dequeue and Peek. A
+// ttl <= 0 means the item never expires.
+func (q *Queue) EnqueueTTL(item interface{}, ttl time.Duration) error {
+	q.Lock()
+	defer q.Unlock()
+	return q.enqueue(item, ttl)
+}
+
+// SetTTL sets the default TTL of the items enqueued by Enqueue and
+// EnqueueMany; items already in the queue are not affected. A ttl <= 0, the
+// default, means that items don't expire.
+func (q *Queue) SetTTL(ttl time.Duration) {
+	q.Lock()
+	defer q.Unlock()
+	q.ttl = ttl
+}
+
+// TTL returns the queue's default TTL.
+func (q *Queue) TTL() time.Duration {
+	q.Lock()
+	defer q.Unlock()
+	return q.ttl
+}
+
+// SetOnExpire sets a function that is called with each expired item when
+// it is discarded. It is called with the queue's lock held, so it must not
+// call any of the queue's methods.
+func (q *Queue) SetOnExpire(f func(item interface{})) {
+	q.Lock()
+	defer q.Unlock()
+	q.onExpire = f
+}
+
+// SetClock sets the clock used to expire items. By default, the system
+// clock is used.
+func (q *Queue) SetClock(clock Clock) {
+	q.Lock()
+	defer q.Unlock()
+	q.clock = clock
+}
+
+// Expired returns the number of expired items that have been discarded.
+func (q *Queue) Expired() uint64 {
+	q.Lock()
+	defer q.Unlock()
+	return q.expired
+}
+
+// setDeadline sets when the item stored at index i of Items expires: once
+// ttl has elapsed, or never if ttl <= 0. The deadlines are kept in a slice
+// parallel to Items, which is only allocated once an item with a TTL is
+// enqueued. The caller is expected to handle locking.
+func (q *Queue) setDeadline(i int, ttl time.Duration) {
+	if q.deadlines == nil {
+		if ttl <= 0 {
+			return
+		}
+		q.deadlines = make([]time.Time, len(q.Items), cap(q.Items))
+	}
+	for len(q.deadlines) < len(q.Items) {
+		q.deadlines = append(q.deadlines, time.Time{})
+	}
+	var d time.Time
+	if ttl > 0 {
+		d = q.now().Add(ttl)
+	}
+	q.deadlines[i] = d
+}
+
+// live returns the item stored at index i of Items and whether it is still
+// live. An expired item is counted and passed to the OnExpire function. The
+// caller is expected to handle locking.
+func (q *Queue) live(i int) (interface{}, bool) {
+	item := q.Items[i]
+	if q.deadlines == nil {
+		return item, true
+	}
+	if q.unexpired(i, q.now()) {
+		return item, true
+	}
+	q.expired++
+	if q.onExpire != nil {
+		q.onExpire(item)
+	}
+	return item, false
+}
+
+// unexpired returns whether the item stored at index i of Items is still
+// live at now. The caller is expected to handle locking.
+func (q *Queue) unexpired(i int, now time.Time) bool {
+	if i >= len(q.deadlines) {
+		return true
+	}
+	d := q.deadlines[i]
+	return d.IsZero() || now.Before(d)
+}
+
+func (q *Queue) now() time.Time {
+	if q.clock == nil {
+		return time.Now()
+	}
+	return q.clock.Now()
+}
@@ -0,0 +1,180 @@
+// Package sim provides a discrete-event simulation engine: events are
+// scheduled at points in virtual time and run in time order, with the
+// virtual clock jumping from one event to the next. Pending events are kept
+// in a calendar queue, so scheduling and running an event take O(1) on
+// average regardless of how many events are pending. Events scheduled for
+// the same time run in the order they were scheduled, so simulations are
+// reproducible.
+package sim
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohae/firkin/queue"
+)
+
+// ErrPast is returned when an event is scheduled before the current time.
+var ErrPast = errors.New("event scheduled before the current time")
+
+// Event is an action that happens at a point in virtual time. It is called
+// with the simulation that is running it, so that it can schedule further
+// events.
+type Event func(s *Simulation)
+
+// Simulation is a discrete-event simulation. Its events are run, one at a
+// time, by Run or Step; events can be scheduled from events or from other
+// goroutines.
+type Simulation struct {
+	mu        sync.Mutex
+	start     time.Time
+	now       time.Time
+	events    *queue.Calendar // Events and timers
+	cancelled int             // the stopped timers still in events
+	stopped   atomic.Bool
+}
+
+// New returns a simulation whose virtual clock starts at start.
+func New(start time.Time) *Simulation {
+	return &Simulation{start: start, now: start, events: queue.NewCalendar()}
+}
+
+// Now returns the current virtual time: the time of the event being run or,
+// between events, of the last event run.
+func (s *Simulation) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Schedule schedules the event to run at the received time. If that time is
+// before the current time, the event is not scheduled and ErrPast is
+// returned.
+func (s *Simulation) Schedule(at time.Time, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at.Before(s.now) {
+		return ErrPast
+	}
+	return s.events.Insert(e, int(at.Sub(s.start)))
+}
+
+// After schedules the event to run once d has elapsed on the virtual clock.
+func (s *Simulation) After(d time.Duration, e Event) error {
+	s.mu.Lock()
+	at := s.now.Add(d)
+	s.mu.Unlock()
+	return s.Schedule(at, e)
+}
+
+// Pending returns the number of events that have been scheduled but not run.
+// Stopped timers are not counted.
+func (s *Simulation) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events.Len() - s.cancelled
+}
+
+// Step runs the next event, advancing the virtual clock to its time. If no
+// events are pending, a false will be returned.
+func (s *Simulation) Step() bool {
+	return s.step(time.Time{}, false)
+}
+
+// step runs the next event, or fires the next timer, if it is due at or
+// before until, or regardless of its time if until isn't checked. Stopped
+// timers are discarded without advancing the virtual clock.
+func (s *Simulation) step(until time.Time, check bool) bool {
+	s.mu.Lock()
+	for {
+		v, p, ok := s.events.PeekValue()
+		if !ok {
+			s.mu.Unlock()
+			return false
+		}
+		if t, isTimer := v.(*timer); isTimer && t.stopped {
+			_, _, _ = s.events.PopValue()
+			s.cancelled--
+			continue
+		}
+		at := s.start.Add(time.Duration(p))
+		if check && at.After(until) {
+			s.mu.Unlock()
+			return false
+		}
+		_, _, _ = s.events.PopValue()
+		s.now = at
+		if t, isTimer := v.(*timer); isTimer {
+			t.stopped = true
+			t.c <- at
+			s.mu.Unlock()
+			return true
+		}
+		s.mu.Unlock()
+		v.(Event)(s)
+		return true
+	}
+}
+
+// Run runs the events that are due at or before until, in time order,
+// including those scheduled while running, and then advances the virtual
+// clock to until. The number of events run is returned. If Stop is called,
+// Run returns once the current event has run, leaving the clock at that
+// event's time.
+func (s *Simulation) Run(until time.Time) int {
+	s.stopped.Store(false)
+	var n int
+	for !s.stopped.Load() && s.step(until, true) {
+		n++
+	}
+	if s.stopped.Load() {
+		return n
+	}
+	s.mu.Lock()
+	if until.After(s.now) {
+		s.now = until
+	}
+	s.mu.Unlock()
+	return n
+}
+
+// Stop stops Run once the current event has run.
+func (s *Simulation) Stop() {
+	s.stopped.Store(true)
+}
+
+// NewTimer returns a timer whose channel receives the virtual time once d
+// has elapsed on the virtual clock. Along with Now, this lets the
+// simulation be used as a queue.Clock, e.g. to drive a queue.Delay. A
+// stopped timer is discarded without advancing the virtual clock to its
+// time.
+func (s *Simulation) NewTimer(d time.Duration) queue.Timer {
+	t := &timer{s: s, c: make(chan time.Time, 1)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.events.Insert(t, int(s.now.Add(max(d, 0)).Sub(s.start)))
+	return t
+}
+
+// timer is a queue.Timer on a simulation's virtual clock. It is kept in the
+// simulation's events until it fires or, once stopped, is discarded.
+type timer struct {
+	s       *Simulation
+	c       chan time.Time
+	stopped bool // set once the timer has fired or been stopped; guarded by s.mu
+}
+
+func (t *timer) C() <-chan time.Time { return t.c }
+
+func (t *timer) Stop() bool {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	t.s.cancelled++
+	return true
+}
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSimulation(t *testing.T) {
+	s := New(start)
+	var got []string
+	record := func(name string) Event {
+		return func(s *Simulation) {
+			got = append(got, name+"@"+s.Now().Sub(start).String())
+		}
+	}
+	_ = s.Schedule(start.Add(3*time.Second), record("c"))
+	_ = s.Schedule(start.Add(time.Second), func(s *Simulation) {
+		record("a")(s)
+		// events scheduled from events run in time order
+		_ = s.After(time.Second, record("b"))
+		_ = s.After(2*time.Second, record("d"))
+		if err := s.Schedule(start, record("x")); err != ErrPast {
+			t.Errorf("expected %q, got %v", ErrPast, err)
+		}
+	})
+	_ = s.Schedule(start.Add(10*time.Second), record("e"))
+	if n := s.Run(start.Add(5 * time.Second)); n != 4 {
+		t.Errorf("expected 4 events to run, got %d", n)
+	}
+	if !s.Now().Equal(start.Add(5 * time.Second)) {
+		t.Errorf("expected the clock to be at 5s, got %v", s.Now().Sub(start))
+	}
+	if s.Pending() != 1 {
+		t.Errorf("expected 1 pending event, got %d", s.Pending())
+	}
+	if !s.Step() || s.Step() {
+		t.Error("expected one more event to step through")
+	}
+	expected := []string{"a@1s", "b@2s", "c@3s", "d@3s", "e@10s"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, v := range expected {
+		if got[i] != v {
+			t.Errorf("%d: expected %s, got %s", i, v, got[i])
+		}
+	}
+}
+
+func TestSimulationStop(t *testing.T) {
+	s := New(start)
+	var n int
+	var tick Event
+	tick = func(s *Simulation) {
+		n++
+		if n == 3 {
+			s.Stop()
+		}
+		_ = s.After(time.Minute, tick)
+	}
+	_ = s.After(time.Minute, tick)
+	if ran := s.Run(start.Add(time.Hour)); ran != 3 {
+		t.Errorf("expected 3 events to run, got %d", ran)
+	}
+	if !s.Now().Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("expected the clock to be at 3m, got %v", s.Now().Sub(start))
+	}
+	s.Run(start.Add(time.Hour))
+	if n != 60 {
+		t.Errorf("expected 60 ticks, got %d", n)
+	}
+}
+
+func TestSimulationTimer(t *testing.T) {
+	s := New(start)
+	t1 := s.NewTimer(time.Second)
+	t2 := s.NewTimer(2 * time.Second)
+	if !t2.Stop() {
+		t.Error("expected stop to return true")
+	}
+	s.Run(start.Add(time.Minute))
+	select {
+	case at := <-t1.C():
+		if !at.Equal(start.Add(time.Second)) {
+			t.Errorf("expected the timer to fire at 1s, got %v", at.Sub(start))
+		}
+	default:
+		t.Error("expected the timer to have fired")
+	}
+	select {
+	case <-t2.C():
+		t.Error("expected the stopped timer to not fire")
+	default:
+	}
+	if t1.Stop() {
+		t.Error("expected stop on a fired timer to return false")
+	}
+}
+
+func TestSimulationStoppedTimer(t *testing.T) {
+	s := New(start)
+	var ran bool
+	_ = s.After(time.Second, func(s *Simulation) { ran = true })
+	for i := 0; i < 10; i++ {
+		s.NewTimer(time.Hour).Stop()
+	}
+	if s.Pending() != 1 {
+		t.Errorf("expected stopped timers to not be pending, got %d", s.Pending())
+	}
+	if !s.Step() || !ran {
+		t.Error("expected the event to run")
+	}
+	// stepping past the stopped timers doesn't advance the clock to them
+	if s.Step() {
+		t.Error("expected no more events to step through")
+	}
+	if !s.Now().Equal(start.Add(time.Second)) {
+		t.Errorf("expected the clock to be at 1s, got %v", s.Now().Sub(start))
+	}
+	if s.Pending() != 0 {
+		t.Errorf("expected no pending events, got %d", s.Pending())
+	}
+}
@@ -3,9 +3,18 @@ package stack
 
 import (
 	"fmt"
+	"io"
+	"iter"
 	"sync"
+
+	"github.com/mohae/firkin/codec"
+	"github.com/mohae/firkin/queue"
 )
 
+// ErrClosed is returned when an item is pushed onto a closed stack. It is the
+// same error as queue.ErrClosed.
+var ErrClosed = queue.ErrClosed
+
 // Stack is a thread-safe LIFO data structure.
 type Stack struct {
 	rw      sync.RWMutex
@@ -13,6 +22,8 @@ type Stack struct {
 	cap     int
 	size    int
 	bounded bool
+	closed  bool
+	codec   codec.Codec
 }
 
 // NewStack returns a new stack with its initial capacity equal to the received
@@ -22,9 +33,13 @@ func NewStack(cap int, bounded bool) *Stack {
 }
 
 // Push an item on the stack. An error will occur is the stack is bounded
-// and at capacity.
+// and at capacity. If the stack is closed, ErrClosed is returned.
 func (s *Stack) Push(item interface{}) error {
 	s.rw.Lock()
+	if s.closed {
+		s.rw.Unlock()
+		return ErrClosed
+	}
 	if s.bounded && s.size == s.cap {
 		s.rw.Unlock()
 		return fmt.Errorf("bounded stack full: cannot push '%v' onto the stack", item)
@@ -41,6 +56,29 @@ func (s *Stack) Push(item interface{}) error {
 	return nil
 }
 
+// PushMany pushes the received items, in order, onto the stack under a
+// single lock acquisition; the last item ends up at the top of the stack. If
+// the stack is bounded, only the items that fit are pushed and an error is
+// returned. The number of items pushed is returned. If the stack is closed,
+// no items are pushed and ErrClosed is returned.
+func (s *Stack) PushMany(items []interface{}) (int, error) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	n := len(items)
+	if s.bounded && s.size+n > s.cap {
+		n = s.cap - s.size
+	}
+	s.items = append(s.items[:s.size], items[:n]...)
+	s.size += n
+	if n < len(items) {
+		return n, fmt.Errorf("bounded stack full: pushed %d of %d items onto the stack", n, len(items))
+	}
+	return n, nil
+}
+
 // Pop pops an item off the stack {}. A nil wil be returned if the stack is
 // empty
 func (s *Stack) Pop() (interface{}, bool) {
@@ -53,6 +91,22 @@ func (s *Stack) Pop() (interface{}, bool) {
 	return s.items[s.size], true
 }
 
+// PopN pops up to n items off the stack under a single lock acquisition.
+// The items are appended to dst, starting with the top of the stack, and the
+// resulting slice is returned.
+func (s *Stack) PopN(n int, dst []interface{}) []interface{} {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if n > s.size {
+		n = s.size
+	}
+	for ; n > 0; n-- {
+		s.size--
+		dst = append(dst, s.items[s.size])
+	}
+	return dst
+}
+
 // Peek returns the item at the top of the stack without popping it. If the
 // stack is empty, it will return nil
 func (s *Stack) Peek() (interface{}, bool) {
@@ -90,3 +144,143 @@ func (s *Stack) Reset() {
 	s.items = make([]interface{}, 0, s.cap)
 	s.rw.Unlock()
 }
+
+// Close closes the stack. Any further attempts to push an item will fail
+// with ErrClosed. Items already on the stack can still be popped; once they
+// have all been popped, IsDrained returns true. Closing an already closed
+// stack returns ErrClosed.
+func (s *Stack) Close() error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	return nil
+}
+
+// IsClosed returns whether or not the stack has been closed.
+func (s *Stack) IsClosed() bool {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	return s.closed
+}
+
+// IsDrained returns whether or not the stack is both closed and empty: no
+// more items will be popped from it.
+func (s *Stack) IsDrained() bool {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	return s.closed && s.size == 0
+}
+
+// All returns an iterator over the items on the stack, from top to bottom.
+// The iterator ranges over a snapshot of the stack taken when iteration
+// starts; the stack is not locked while the items are yielded.
+func (s *Stack) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for _, item := range s.snapshot() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Indexed returns an iterator over the position and item of each item on
+// the stack, from the top, position 0, to the bottom. Like All, it ranges
+// over a snapshot of the stack.
+func (s *Stack) Indexed() iter.Seq2[int, interface{}] {
+	return func(yield func(int, interface{}) bool) {
+		for i, item := range s.snapshot() {
+			if !yield(i, item) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that pops the items off the stack, yielding each
+// one, until the stack is empty. Items pushed while draining are also
+// yielded. Stopping the iteration early leaves the remaining items on the
+// stack.
+func (s *Stack) Drain() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for {
+			item, ok := s.Pop()
+			if !ok || !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the items on the stack, from top to bottom.
+func (s *Stack) snapshot() []interface{} {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	tmp := make([]interface{}, s.size)
+	for i := range tmp {
+		tmp[i] = s.items[s.size-1-i]
+	}
+	return tmp
+}
+
+// SetCodec sets the codec used by Snapshot and Restore to encode and decode
+// the stack's items. By default, codec.Gob is used.
+func (s *Stack) SetCodec(c codec.Codec) {
+	s.rw.Lock()
+	s.codec = c
+	s.rw.Unlock()
+}
+
+// itemCodec returns the stack's codec. The caller is expected to handle
+// locking.
+func (s *Stack) itemCodec() codec.Codec {
+	if s.codec == nil {
+		return codec.Gob{}
+	}
+	return s.codec
+}
+
+// Snapshot writes the stack's items, from bottom to top, along with its
+// capacity and whether or not it is bounded, to w. The items are copied
+// under the lock and encoded after it is released.
+func (s *Stack) Snapshot(w io.Writer) error {
+	s.rw.RLock()
+	snap := &codec.Snapshot{
+		Kind:    "stack",
+		Cap:     s.cap,
+		InitCap: s.cap,
+		Bounded: s.bounded,
+		Items:   append([]interface{}(nil), s.items[:s.size]...),
+	}
+	c := s.itemCodec()
+	s.rw.RUnlock()
+	return codec.Encode(w, c, snap)
+}
+
+// Restore replaces the stack's items and configuration with those of a
+// snapshot written by Snapshot. Any items on the stack will be lost.
+func (s *Stack) Restore(r io.Reader) error {
+	s.rw.RLock()
+	c := s.itemCodec()
+	s.rw.RUnlock()
+	snap, err := codec.Decode(r, c)
+	if err != nil {
+		return err
+	}
+	if snap.Kind != "stack" {
+		return fmt.Errorf("cannot restore a %s snapshot to a stack", snap.Kind)
+	}
+	if snap.Bounded && len(snap.Items) > snap.Cap {
+		return fmt.Errorf("cannot restore %d items to a bounded stack with a capacity of %d", len(snap.Items), snap.Cap)
+	}
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.cap = snap.Cap
+	s.bounded = snap.Bounded
+	s.items = append(make([]interface{}, 0, max(s.cap, len(snap.Items))), snap.Items...)
+	s.size = len(snap.Items)
+	return nil
+}
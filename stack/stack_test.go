@@ -1,7 +1,12 @@
 package stack
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+
+	"github.com/mohae/firkin/codec"
+	"github.com/mohae/firkin/queue"
 )
 
 func TestPushStack(t *testing.T) {
@@ -85,3 +90,137 @@ func TestPushStack(t *testing.T) {
 	Next:
 	}
 }
+
+func TestStackClose(t *testing.T) {
+	s := NewStack(2, false)
+	_ = s.Push(0)
+	if err := s.Close(); err != nil {
+		t.Errorf("unexpected error: %q", err)
+	}
+	if err := s.Push(1); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("expected %q, got %v", queue.ErrClosed, err)
+	}
+	if !s.IsClosed() || s.IsDrained() {
+		t.Errorf("expected stack to be closed but not drained: %t %t", s.IsClosed(), s.IsDrained())
+	}
+	if v, ok := s.Pop(); !ok || v != 0 {
+		t.Errorf("expected 0, got %v", v)
+	}
+	if !s.IsDrained() {
+		t.Error("expected stack to be drained")
+	}
+	if err := s.Close(); err != ErrClosed {
+		t.Errorf("expected second close to return %q, got %v", ErrClosed, err)
+	}
+}
+
+func TestStackPushManyPopN(t *testing.T) {
+	tests := []struct {
+		cap         int
+		bounded     bool
+		pre         []interface{}
+		items       []interface{}
+		pushed      int
+		expectedErr string
+		n           int
+		popped      []interface{}
+	}{
+		{4, false, nil, []interface{}{0, 1, 2}, 3, "", 2, []interface{}{2, 1}},
+		{2, false, []interface{}{0}, []interface{}{1, 2, 3}, 3, "", 10, []interface{}{3, 2, 1, 0}},
+		{3, true, []interface{}{0}, []interface{}{1, 2, 3}, 2, "bounded stack full: pushed 2 of 3 items onto the stack", 3, []interface{}{2, 1, 0}},
+		{3, true, nil, []interface{}{}, 0, "", 1, []interface{}{}},
+	}
+	for i, test := range tests {
+		s := NewStack(test.cap, test.bounded)
+		for _, v := range test.pre {
+			_ = s.Push(v)
+		}
+		n, err := s.PushMany(test.items)
+		if n != test.pushed {
+			t.Errorf("%d: expected %d items to be pushed, got %d", i, test.pushed, n)
+		}
+		if err != nil && err.Error() != test.expectedErr {
+			t.Errorf("%d: expected error to be %q, got %q", i, test.expectedErr, err)
+		}
+		if err == nil && test.expectedErr != "" {
+			t.Errorf("%d: expected error %q, got none", i, test.expectedErr)
+		}
+		got := s.PopN(test.n, nil)
+		if len(got) != len(test.popped) {
+			t.Errorf("%d: expected %v, got %v", i, test.popped, got)
+			continue
+		}
+		for j, v := range test.popped {
+			if got[j] != v {
+				t.Errorf("%d: item %d: expected %v, got %v", i, j, v, got[j])
+			}
+		}
+	}
+}
+
+func TestStackIterators(t *testing.T) {
+	s := NewStack(4, false)
+	for i := 0; i < 4; i++ {
+		_ = s.Push(i)
+	}
+	for i, v := range s.Indexed() {
+		if v != 3-i {
+			t.Errorf("Indexed: item %d: expected %d, got %v", i, 3-i, v)
+		}
+	}
+	n := 0
+	for v := range s.All() {
+		if v != 3-n {
+			t.Errorf("All: item %d: expected %d, got %v", n, 3-n, v)
+		}
+		n++
+	}
+	n = 0
+	for v := range s.Drain() {
+		if v != 3-n {
+			t.Errorf("Drain: item %d: expected %d, got %v", n, 3-n, v)
+		}
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if s.Size() != 2 {
+		t.Errorf("expected 2 items to remain after stopping Drain, got %d", s.Size())
+	}
+}
+
+func TestStackSnapshotRestore(t *testing.T) {
+	s := NewStack(3, true)
+	_, _ = s.PushMany([]interface{}{0, 1, 2})
+	var buf bytes.Buffer
+	if err := s.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: unexpected error: %q", err)
+	}
+	r := NewStack(1, false)
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("restore: unexpected error: %q", err)
+	}
+	if err := r.Push(3); err == nil {
+		t.Error("expected the restored stack to be bounded and full")
+	}
+	got := r.PopN(3, nil)
+	for i, v := range []interface{}{2, 1, 0} {
+		if got[i] != v {
+			t.Errorf("item %d: expected %v, got %v", i, v, got[i])
+		}
+	}
+}
+
+func TestStackRestoreCorrupt(t *testing.T) {
+	for i, snap := range []*codec.Snapshot{
+		{Kind: "stack", Cap: -1},
+		{Kind: "stack", Cap: 1 << 40, Items: []interface{}{0}},
+	} {
+		var buf bytes.Buffer
+		_ = codec.Encode(&buf, codec.Gob{}, snap)
+		if err := NewStack(1, false).Restore(&buf); !errors.Is(err, codec.ErrBadSnapshot) {
+			t.Errorf("%d: expected %q, got %v", i, codec.ErrBadSnapshot, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package stack
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StackOf is a thread-safe LIFO data structure of T. It has the same
+// semantics as Stack.
+type StackOf[T any] struct {
+	rw      sync.RWMutex
+	items   []T
+	cap     int
+	size    int
+	bounded bool
+}
+
+// NewStackOf returns a new stack of T with its initial capacity equal to the
+// received size and bounded set accordingly.
+func NewStackOf[T any](cap int, bounded bool) *StackOf[T] {
+	return &StackOf[T]{items: make([]T, 0, cap), cap: cap, bounded: bounded}
+}
+
+// Push an item on the stack. An error will occur is the stack is bounded
+// and at capacity.
+func (s *StackOf[T]) Push(item T) error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if s.bounded && s.size == s.cap {
+		return fmt.Errorf("bounded stack full: cannot push '%v' onto the stack", item)
+	}
+	if s.size == len(s.items) {
+		s.items = append(s.items, item)
+	} else {
+		s.items[s.size] = item
+	}
+	s.size++
+	return nil
+}
+
+// Pop pops an item off the stack. If the stack is empty, the zero value of
+// T and a false will be returned.
+func (s *StackOf[T]) Pop() (T, bool) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	var zero T
+	if s.size == 0 {
+		return zero, false
+	}
+	s.size--
+	item := s.items[s.size]
+	s.items[s.size] = zero
+	return item, true
+}
+
+// Peek returns the item at the top of the stack without popping it. If the
+// stack is empty, a false will be returned.
+func (s *StackOf[T]) Peek() (T, bool) {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	if s.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.items[s.size-1], true
+}
+
+// IsEmpty returns whether or not the stack is empty
+func (s *StackOf[T]) IsEmpty() bool {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	return s.size == 0
+}
+
+// Size returns the current size of the stack (number of items)
+func (s *StackOf[T]) Size() int {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	return s.size
+}
+
+// Reset resets the stack: the capacity of the stack will be reset to its
+// initial capacity. Anything in the stack will be lost
+func (s *StackOf[T]) Reset() {
+	s.rw.Lock()
+	s.size = 0
+	s.items = make([]T, 0, s.cap)
+	s.rw.Unlock()
+}
@@ -0,0 +1,55 @@
+package stack
+
+import (
+	"testing"
+)
+
+func TestStackOf(t *testing.T) {
+	tests := []struct {
+		cap         int
+		bounded     bool
+		pushVals    []int
+		size        int
+		expectedErr string
+		pop         []int
+	}{
+		{4, false, []int{}, 0, "", []int{}},
+		{4, false, []int{0, 1}, 2, "", []int{1, 0}},
+		{2, false, []int{0, 1, 2, 3}, 4, "", []int{3, 2, 1, 0}},
+		{2, true, []int{0, 1, 2}, 2, "bounded stack full: cannot push '2' onto the stack", []int{1, 0}},
+	}
+	for i, test := range tests {
+		s := NewStackOf[int](test.cap, test.bounded)
+		var err error
+		for _, v := range test.pushVals {
+			if e := s.Push(v); e != nil {
+				err = e
+			}
+		}
+		if err != nil && err.Error() != test.expectedErr {
+			t.Errorf("%d: expected error to be %q, got %q", i, test.expectedErr, err)
+		}
+		if err == nil && test.expectedErr != "" {
+			t.Errorf("%d: expected error %q, got none", i, test.expectedErr)
+		}
+		if s.Size() != test.size {
+			t.Errorf("%d: expected size to be %d, got %d", i, test.size, s.Size())
+		}
+		for j, v := range test.pop {
+			val, ok := s.Peek()
+			if !ok || val != v {
+				t.Errorf("%d peek #%d: expected %d, got %d (%t)", i, j, v, val, ok)
+			}
+			val, ok = s.Pop()
+			if !ok || val != v {
+				t.Errorf("%d pop #%d: expected %d, got %d (%t)", i, j, v, val, ok)
+			}
+		}
+		if !s.IsEmpty() {
+			t.Errorf("%d: expected stack to be empty", i)
+		}
+		if _, ok := s.Pop(); ok {
+			t.Errorf("%d: expected pop on an empty stack to return false", i)
+		}
+	}
+}